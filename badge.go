@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// =============================================================================
+// Total Contributions Badge (--output badge)
+// =============================================================================
+
+// Badge geometry: a fixed size that fits a seven-digit total next to the cell.
+const (
+	badgeWidth  = 80
+	badgeHeight = 20
+)
+
+// generateBadgeSVG writes a small badge with the total contributions of grids
+// and one cell colored by their activity tier to outputFilename.
+func generateBadgeSVG(grids []LabeledWeeks, outputFilename string, opts MapOptions) error {
+	total, days, activeDays := 0, 0, 0
+	for _, grid := range grids {
+		stats := computeStats(grid.Weeks)
+		total += grid.Total
+		days += stats.Days
+		activeDays += stats.ActiveDays
+	}
+	theme := opts.Theme
+	label := formatThousands(total)
+
+	background := ""
+	if !theme.Transparent {
+		background = fmt.Sprintf(`<rect width="%d" height="%d" rx="3" fill="%s"/>`, badgeWidth, badgeHeight, theme.Background)
+	}
+	svg := fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" role="img" aria-label="%s contributions">%s<rect x="3" y="3" width="14" height="14" rx="2" fill="%s"/><text x="22" y="14" fill="%s" font-family="sans-serif" font-size="11">%s</text></svg>`,
+		badgeWidth, badgeHeight, label, background, activityTierColor(activeDays, days, theme), contrastColor(theme.Background), label)
+	return writeOutputFile(outputFilename, []byte(svg))
+}
+
+// activityTierColor picks the bucket for the share of days with any
+// contributions, from the darkest for a few to the brightest for every day,
+// and the zero color for none.
+func activityTierColor(activeDays, days int, theme Theme) string {
+	if activeDays == 0 || days == 0 {
+		return theme.Zero
+	}
+	share := float64(activeDays) / float64(days)
+	tier := int(math.Ceil(share*float64(len(theme.Buckets)))) - 1
+	return theme.Buckets[min(max(tier, 0), len(theme.Buckets)-1)]
+}
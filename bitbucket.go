@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// =============================================================================
+// Bitbucket Cloud API
+// =============================================================================
+
+// Default Bitbucket Cloud REST API base (overridable with --bitbucket-url).
+const defaultBitbucketURL = "https://api.bitbucket.org/2.0"
+
+// Bitbucket has no contributions calendar or per-user activity feed, so the
+// graph is approximated from the repositories the user can be seen in:
+//
+//   - GET /repositories/{user} lists the user's own workspace, and with a
+//     token GET /repositories?role=member adds every repository the token's
+//     account belongs to;
+//   - GET /repositories/{repo}/commits counts commits whose author is linked
+//     to the user (commits by unlinked email addresses are missed);
+//   - GET /repositories/{repo}/pullrequests counts pull requests the user
+//     opened, and approvals on other people's pull requests as code reviews;
+//   - GET /repositories/{repo}/issues counts issues the user reported, where
+//     the issue tracker is enabled.
+//
+// Each activity lands on the day it happened in the configured time zone.
+// This costs several requests per repository, so large workspaces are slow.
+
+// bitbucketUser is the account object embedded in Bitbucket API responses.
+type bitbucketUser struct {
+	Nickname string `json:"nickname"`
+	Username string `json:"username"` // only set on older accounts
+}
+
+// is reports whether the account is the given user name.
+func (u *bitbucketUser) is(username string) bool {
+	return u != nil && (strings.EqualFold(u.Nickname, username) || strings.EqualFold(u.Username, username))
+}
+
+type bitbucketRepository struct {
+	FullName string `json:"full_name"`
+}
+
+type bitbucketCommit struct {
+	Date   string `json:"date"`
+	Author struct {
+		User *bitbucketUser `json:"user"`
+	} `json:"author"`
+}
+
+type bitbucketPullRequest struct {
+	CreatedOn    string         `json:"created_on"`
+	Author       *bitbucketUser `json:"author"`
+	Participants []struct {
+		User           *bitbucketUser `json:"user"`
+		Approved       bool           `json:"approved"`
+		ParticipatedOn string         `json:"participated_on"`
+	} `json:"participants"`
+}
+
+type bitbucketIssue struct {
+	CreatedOn string         `json:"created_on"`
+	Reporter  *bitbucketUser `json:"reporter"`
+}
+
+// fetchBitbucketContributions approximates a trailing-year contribution graph
+// for username from the endpoints described above. The token is either an
+// app password given as "username:app-password" or an access token.
+func fetchBitbucketContributions(ctx context.Context, baseURL, username, token string, loc *time.Location) (Weeks, CrossData, error) {
+	today, windowStart := trailingYearWindow(loc)
+	since := windowStart.UTC().Format("2006-01-02")
+	counts := make(map[string]int)
+	var crossData CrossData
+
+	// record counts one activity at timestamp if it falls inside the window.
+	record := func(timestamp string) bool {
+		t, err := time.Parse(time.RFC3339, timestamp)
+		if err != nil {
+			return false
+		}
+		t = t.In(loc)
+		if t.Before(windowStart) {
+			return false
+		}
+		counts[t.Format("2006-01-02")]++
+		return true
+	}
+
+	repos, err := bitbucketRepositories(ctx, baseURL, username, token)
+	if err != nil {
+		return nil, CrossData{}, err
+	}
+	verboseLog.Printf("Scanning %d Bitbucket repositories for %s", len(repos), username)
+
+	for _, repo := range repos {
+		repoURL := baseURL + "/repositories/" + repo
+
+		// Commits come newest first; stop at the first one before the window.
+		err := bitbucketPages(ctx, repoURL+"/commits?pagelen=100", token, func(raw json.RawMessage) (bool, error) {
+			var commits []bitbucketCommit
+			if err := json.Unmarshal(raw, &commits); err != nil {
+				return false, err
+			}
+			for _, c := range commits {
+				if t, err := time.Parse(time.RFC3339, c.Date); err == nil && t.Before(windowStart) {
+					return false, nil
+				}
+				if c.Author.User.is(username) && record(c.Date) {
+					crossData.Commits++
+				}
+			}
+			return true, nil
+		})
+		if err != nil {
+			return nil, CrossData{}, err
+		}
+
+		query := url.Values{
+			"state":   {"OPEN", "MERGED", "DECLINED", "SUPERSEDED"},
+			"q":       {fmt.Sprintf("updated_on >= %s", since)},
+			"fields":  {"+values.participants"},
+			"pagelen": {"50"},
+		}
+		err = bitbucketPages(ctx, repoURL+"/pullrequests?"+query.Encode(), token, func(raw json.RawMessage) (bool, error) {
+			var pulls []bitbucketPullRequest
+			if err := json.Unmarshal(raw, &pulls); err != nil {
+				return false, err
+			}
+			for _, pr := range pulls {
+				if pr.Author.is(username) {
+					if record(pr.CreatedOn) {
+						crossData.PullRequests++
+					}
+					continue
+				}
+				for _, p := range pr.Participants {
+					if p.User.is(username) && p.Approved && record(p.ParticipatedOn) {
+						crossData.CodeReviews++
+					}
+				}
+			}
+			return true, nil
+		})
+		if err != nil {
+			return nil, CrossData{}, err
+		}
+
+		query = url.Values{
+			"q":       {fmt.Sprintf("created_on >= %s", since)},
+			"pagelen": {"50"},
+		}
+		err = bitbucketPages(ctx, repoURL+"/issues?"+query.Encode(), token, func(raw json.RawMessage) (bool, error) {
+			var issues []bitbucketIssue
+			if err := json.Unmarshal(raw, &issues); err != nil {
+				return false, err
+			}
+			for _, issue := range issues {
+				if issue.Reporter.is(username) && record(issue.CreatedOn) {
+					crossData.Issues++
+				}
+			}
+			return true, nil
+		})
+		// Repositories without an issue tracker answer 404.
+		if err != nil && !isBitbucketNotFound(err) {
+			return nil, CrossData{}, err
+		}
+	}
+
+	weeks := buildWeeks(counts, windowStart, today)
+	verboseLog.Printf("Built %d weeks for Bitbucket user %s", len(weeks), username)
+	return weeks, crossData, nil
+}
+
+// bitbucketRepositories returns the full names of the user's workspace
+// repositories and, with a token, of every repository its account is a member of.
+func bitbucketRepositories(ctx context.Context, baseURL, username, token string) ([]string, error) {
+	seen := make(map[string]bool)
+	var repos []string
+	collect := func(raw json.RawMessage) (bool, error) {
+		var page []bitbucketRepository
+		if err := json.Unmarshal(raw, &page); err != nil {
+			return false, err
+		}
+		for _, r := range page {
+			if !seen[r.FullName] {
+				seen[r.FullName] = true
+				repos = append(repos, r.FullName)
+			}
+		}
+		return true, nil
+	}
+
+	if err := bitbucketPages(ctx, baseURL+"/repositories/"+url.PathEscape(username)+"?pagelen=100", token, collect); err != nil {
+		if isBitbucketNotFound(err) {
+			return nil, withCode(errCodeNotFound, fmt.Errorf("Bitbucket workspace %q was not found", username))
+		}
+		return nil, err
+	}
+	if token != "" {
+		if err := bitbucketPages(ctx, baseURL+"/repositories?role=member&pagelen=100", token, collect); err != nil {
+			return nil, err
+		}
+	}
+	return repos, nil
+}
+
+// bitbucketStatusError is a non-200 answer from the Bitbucket API.
+type bitbucketStatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *bitbucketStatusError) Error() string {
+	return fmt.Sprintf("Bitbucket API error: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Body)
+}
+
+// isBitbucketNotFound reports whether err is a 404 from the Bitbucket API.
+func isBitbucketNotFound(err error) bool {
+	statusErr, ok := err.(*bitbucketStatusError)
+	return ok && statusErr.StatusCode == http.StatusNotFound
+}
+
+// bitbucketPages requests pageURL and follows the "next" links of the paged
+// responses, handing each page's values to each until it returns false.
+func bitbucketPages(ctx context.Context, pageURL, token string, each func(values json.RawMessage) (bool, error)) error {
+	for pageURL != "" {
+		req, err := http.NewRequestWithContext(ctx, "GET", pageURL, nil)
+		if err != nil {
+			return err
+		}
+		setBitbucketAuth(req, token)
+		verboseLog.Printf("GET %s (token: %s)", pageURL, redactToken(token))
+
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		verboseLog.Printf("Bitbucket responded %s", resp.Status)
+		if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			return &bitbucketStatusError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(body))}
+		}
+
+		var page struct {
+			Values json.RawMessage `json:"values"`
+			Next   string          `json:"next"`
+		}
+		err = decodeJSONResponse(resp, &page)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+		progress.pageFetched()
+		more, err := each(page.Values)
+		if err != nil || !more {
+			return err
+		}
+		pageURL = page.Next
+	}
+	return nil
+}
+
+// setBitbucketAuth authenticates req with an app password given as
+// "username:app-password", or with any other token as a bearer token.
+func setBitbucketAuth(req *http.Request, token string) {
+	if token == "" {
+		return
+	}
+	if user, password, ok := strings.Cut(token, ":"); ok {
+		req.SetBasicAuth(user, password)
+		return
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// =============================================================================
+// Disk Cache for Fetched Contributions
+// =============================================================================
+
+// Default lifetime of a cached fetch (overridable with --cache-ttl).
+const defaultCacheTTL = time.Hour
+
+// cacheEntry is the on-disk form of one fetch.
+type cacheEntry struct {
+	FetchedAt time.Time `json:"fetchedAt"`
+	Weeks     Weeks     `json:"weeks"`
+	CrossData CrossData `json:"crossData"`
+	Total     int       `json:"total,omitempty"` // platform-reported yearly total; see total
+	ETag      string    `json:"etag,omitempty"`  // validator of the response, for revalidating the entry once it expires
+}
+
+// total returns the recorded yearly total, or the sum of the daily counts for
+// entries written before it was recorded.
+func (e cacheEntry) total() int {
+	if e.Total > 0 {
+		return e.Total
+	}
+	return computeStats(e.Weeks).TotalContributions
+}
+
+// defaultCacheDir returns the per-user cache directory for contribmap, or ""
+// if the platform has none.
+func defaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "contribmap")
+}
+
+// cacheKey identifies a fetch by platform, instance, user, the token it was
+// made with and the date range it covers. The token matters because it may see
+// private contributions that an anonymous or other fetch does not; it is only
+// hashed into the key, never stored. days names the range: today's date for
+// the trailing year ending today, or the --range window.
+func cacheKey(platform, instance, user, token, days string) string {
+	sum := sha256.Sum256([]byte(platform + "\n" + instance + "\n" + user + "\n" + token + "\n" + days))
+	return hex.EncodeToString(sum[:16])
+}
+
+// loadCache returns the cached fetch for key if one exists in dir and is
+// younger than ttl. An expired entry is returned too, with fresh false, so
+// that its ETag can revalidate it.
+func loadCache(dir, key string, ttl time.Duration) (entry cacheEntry, found, fresh bool) {
+	data, err := os.ReadFile(filepath.Join(dir, key+".json"))
+	if err != nil {
+		return cacheEntry{}, false, false
+	}
+	if err := json.Unmarshal(data, &entry); err != nil {
+		verboseLog.Printf("Ignoring unreadable cache entry %s: %v", key, err)
+		return cacheEntry{}, false, false
+	}
+	age := time.Since(entry.FetchedAt)
+	return entry, true, age >= 0 && age <= ttl
+}
+
+// saveCache stores a fetch under key in dir, creating dir if needed.
+func saveCache(dir, key string, weeks Weeks, crossData CrossData, total int, etag string) error {
+	data, err := json.Marshal(cacheEntry{FetchedAt: time.Now(), Weeks: weeks, CrossData: crossData, Total: total, ETag: etag})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("creating cache directory %s: %w", dir, err)
+	}
+	return writeOutputFile(filepath.Join(dir, key+".json"), data)
+}
+
+// errNotModified is returned by a fetch that was answered 304 Not Modified to
+// the ETag of an expired cache entry; the entry is still current.
+var errNotModified = errors.New("not modified since the cached fetch")
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+)
+
+// =============================================================================
+// Month Calendar View (--view calendar-months)
+// =============================================================================
+
+// Views accepted by --view.
+const (
+	viewStrip          = "strip"
+	viewCalendarMonths = "calendar-months"
+)
+
+// Layout of the month calendar view: twelve blocks in calendarColumns columns,
+// each block a week grid of up to six rows starting on the layout's WeekStart.
+const (
+	calendarMonths  = 12
+	calendarColumns = 4
+	calendarRows    = 6
+)
+
+// calendarBlockSize returns the width and height of one month block,
+// including the row for its title, and the gap left between blocks.
+func calendarBlockSize(layout MapLayout) (width, height, gap int) {
+	pitch := layout.CellSize + layout.CellMargin
+	return 7*pitch + layout.CellMargin, layout.topMargin() + calendarRows*pitch + layout.CellMargin, 2 * pitch
+}
+
+// calendarSize returns the width and height of one user's twelve blocks.
+func calendarSize(layout MapLayout) (int, int) {
+	width, height, gap := calendarBlockSize(layout)
+	rows := (calendarMonths + calendarColumns - 1) / calendarColumns
+	return calendarColumns*width + (calendarColumns-1)*gap, rows*height + (rows-1)*gap
+}
+
+// calendarMonthStarts returns the first days of the twelve months ending with
+// the month of the last dated day in weeks, oldest first. Without any dated
+// day it ends with the current month, so only then does the output depend on
+// the clock.
+func calendarMonthStarts(weeks Weeks) []time.Time {
+	last := time.Now()
+	for _, week := range weeks {
+		for _, day := range week {
+			if t, err := time.Parse("2006-01-02", day.Date); err == nil {
+				last = t
+			}
+		}
+	}
+	current := time.Date(last.Year(), last.Month(), 1, 0, 0, 0, 0, time.UTC)
+	starts := make([]time.Time, calendarMonths)
+	for i := range starts {
+		starts[i] = current.AddDate(0, i-(calendarMonths-1), 0)
+	}
+	return starts
+}
+
+// generateCalendarSVG writes the month calendars of grids, stacked vertically
+// and labeled when there are several, to outputFilename.
+func generateCalendarSVG(grids []LabeledWeeks, outputFilename string, opts MapOptions) error {
+	data, err := renderCalendarSVG(grids, opts)
+	if err != nil {
+		return err
+	}
+	return writeSVGFile(outputFilename, data, opts.Gzip)
+}
+
+// renderCalendarSVG returns the SVG written by generateCalendarSVG.
+func renderCalendarSVG(grids []LabeledWeeks, opts MapOptions) ([]byte, error) {
+	layout := opts.Layout
+	if err := layout.validate(); err != nil {
+		return nil, err
+	}
+	headerHeight := 0
+	if len(grids) > 1 {
+		headerHeight = layout.topMargin()
+	}
+	width, height := calendarSize(layout)
+	svgWidth := width + 2*layout.CellMargin
+	svgHeight := len(grids) * (headerHeight + height + layout.CellMargin)
+	if svgWidth > maxSVGDimension || svgHeight > maxSVGDimension {
+		return nil, fmt.Errorf("calendar of %dx%d exceeds the maximum SVG size", svgWidth, svgHeight)
+	}
+
+	var svg bytes.Buffer
+	writeSVGHeader(&svg, svgWidth, svgHeight, "Contributions by month", multiMapSummary(grids))
+	opts.Theme.writeBackground(&svg, svgWidth, svgHeight)
+
+	textFill := contrastColor(opts.Theme.Background)
+	offsetY := 0
+	for _, grid := range grids {
+		if headerHeight > 0 {
+			svg.WriteString(fmt.Sprintf(`<text x="%d" y="%d" fill="%s" font-family="sans-serif" font-size="%dpx" font-weight="bold">%s</text>`, layout.CellMargin, offsetY+headerHeight-4, textFill, layout.labelFontSize()+2, escapeXML(grid.Label)))
+			svg.WriteString("\n")
+			offsetY += headerHeight
+		}
+		writeCalendarMonths(&svg, grid.Weeks, layout.CellMargin, offsetY, opts)
+		offsetY += height + layout.CellMargin
+	}
+	svg.WriteString("</svg>")
+	return finishSVG(svg.Bytes(), opts.Minify, opts.StripTooltips), nil
+}
+
+// writeCalendarMonths draws twelve titled month blocks with their top-left
+// corner at (originX, originY). Days outside the fetched window are drawn in
+// the zero color without a tooltip.
+func writeCalendarMonths(svg *bytes.Buffer, weeks Weeks, originX, originY int, opts MapOptions) {
+	layout := opts.Layout
+	pitch := layout.CellSize + layout.CellMargin
+	blockWidth, blockHeight, gap := calendarBlockSize(layout)
+	textFill := contrastColor(opts.Theme.Background)
+
+	days := make(map[string]ContributionDay)
+	for _, week := range weeks {
+		for _, day := range week {
+			if day.Date != "" {
+				days[day.Date] = day
+			}
+		}
+	}
+
+	for i, start := range calendarMonthStarts(weeks) {
+		blockX := originX + (i%calendarColumns)*(blockWidth+gap)
+		blockY := originY + (i/calendarColumns)*(blockHeight+gap)
+		svg.WriteString(fmt.Sprintf(`<text x="%d" y="%d" fill="%s" font-family="sans-serif" font-size="%dpx">%s</text>`, blockX+layout.CellMargin, blockY+layout.topMargin()-4, textFill, layout.labelFontSize(), fmt.Sprintf("%s %d", layout.Locale.month(start.Month()), start.Year())))
+		svg.WriteString("\n")
+
+		offset := layout.weekdayRow(start.Weekday())
+		for d := start; d.Month() == start.Month(); d = d.AddDate(0, 0, 1) {
+			slot := offset + d.Day() - 1
+			x := blockX + layout.CellMargin + (slot%7)*pitch
+			y := blockY + layout.topMargin() + layout.CellMargin + (slot/7)*pitch
+			date := d.Format("2006-01-02")
+			day, ok := days[date]
+			if !ok {
+				svg.WriteString(fmt.Sprintf(`<rect x="%d" y="%d" width="%d" height="%d" fill="%s"/>`, x, y, layout.CellSize, layout.CellSize, opts.Theme.Zero))
+				svg.WriteString("\n")
+				continue
+			}
+			tooltip := opts.dayTooltip(date, day.Count)
+			svg.WriteString(fmt.Sprintf(`<rect x="%d" y="%d" width="%d" height="%d" fill="%s"%s aria-label="%s">
+  <title>%s</title>
+</rect>`, x, y, layout.CellSize, layout.CellSize, day.Color, day.opacityAttr(), escapeXML(tooltip), escapeXML(tooltip)))
+			svg.WriteString("\n")
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// =============================================================================
+// Weekly and Monthly Bar Charts (--granularity)
+// =============================================================================
+
+// Granularities accepted by --granularity; daily is the heatmap itself.
+const (
+	granularityDaily   = "daily"
+	granularityWeekly  = "weekly"
+	granularityMonthly = "monthly"
+)
+
+// PeriodTotal is the contribution count of one bar in a bar chart.
+type PeriodTotal struct {
+	Label string // drawn below the bar; empty for most weekly bars
+	Title string // tooltip
+	Count int
+}
+
+// aggregatePeriods sums the days of weeks into one total per grid column
+// (weekly) or per calendar month (monthly), skipping padding days. Months are
+// labeled in locale.
+func aggregatePeriods(weeks Weeks, granularity string, locale labelLocale) []PeriodTotal {
+	var periods []PeriodTotal
+	lastMonth := ""
+	for _, week := range weeks {
+		if granularity == granularityMonthly {
+			for _, day := range week {
+				if day.Date == "" {
+					continue
+				}
+				if month := day.Date[:7]; month != lastMonth {
+					periods = append(periods, PeriodTotal{Label: monthAbbrev(day.Date, locale), Title: month})
+					lastMonth = month
+				}
+				periods[len(periods)-1].Count += day.Count
+			}
+			continue
+		}
+
+		first, count := "", 0
+		for _, day := range week {
+			if day.Date == "" {
+				continue
+			}
+			if first == "" {
+				first = day.Date
+			}
+			count += day.Count
+		}
+		if first == "" {
+			continue
+		}
+		// Only the first bar of each month is labeled.
+		label := ""
+		if month := first[:7]; month != lastMonth {
+			label = monthAbbrev(first, locale)
+			lastMonth = month
+		}
+		periods = append(periods, PeriodTotal{Label: label, Title: "Week of " + first, Count: count})
+	}
+	for i := range periods {
+		periods[i].Title = fmt.Sprintf("%s: %d contributions", periods[i].Title, periods[i].Count)
+	}
+	return periods
+}
+
+// monthAbbrev returns the short month name of a YYYY-MM-DD date in locale.
+func monthAbbrev(date string, locale labelLocale) string {
+	t, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return ""
+	}
+	return locale.month(t.Month())
+}
+
+// barWidth returns the width of one bar: a cell for weeks, four for months.
+func barWidth(granularity string, layout MapLayout) int {
+	if granularity == granularityMonthly {
+		return 4*layout.CellSize + 3*layout.CellMargin
+	}
+	return layout.CellSize
+}
+
+// barChartSize returns the width and height of a chart of n bars. The plot is
+// as tall as the heatmap grid, with the top margin left for monthly counts and
+// a row below for the period labels.
+func barChartSize(n int, granularity string, layout MapLayout) (int, int) {
+	plotHeight := 7*(layout.CellSize+layout.CellMargin) + layout.CellMargin
+	width := n*(barWidth(granularity, layout)+layout.CellMargin) + layout.CellMargin
+	height := layout.topMargin() + plotHeight + layout.labelFontSize() + 4
+	return width, height
+}
+
+// generateBarChartSVG writes the bar charts of grids, stacked vertically and
+// labeled when there are several, to outputFilename.
+func generateBarChartSVG(grids []LabeledWeeks, granularity, outputFilename string, opts MapOptions) error {
+	data, err := renderBarChartSVG(grids, granularity, opts)
+	if err != nil {
+		return err
+	}
+	return writeSVGFile(outputFilename, data, opts.Gzip)
+}
+
+// renderBarChartSVG returns the SVG written by generateBarChartSVG.
+func renderBarChartSVG(grids []LabeledWeeks, granularity string, opts MapOptions) ([]byte, error) {
+	layout := opts.Layout
+	if err := layout.validate(); err != nil {
+		return nil, err
+	}
+	headerHeight := 0
+	if len(grids) > 1 {
+		headerHeight = layout.topMargin()
+	}
+
+	charts := make([][]PeriodTotal, len(grids))
+	svgWidth, svgHeight := 0, 0
+	for i, grid := range grids {
+		charts[i] = aggregatePeriods(grid.Weeks, granularity, layout.Locale)
+		width, height := barChartSize(len(charts[i]), granularity, layout)
+		svgWidth = max(svgWidth, width)
+		svgHeight += headerHeight + height
+	}
+	if svgWidth > maxSVGDimension || svgHeight > maxSVGDimension {
+		return nil, fmt.Errorf("bar chart of %dx%d exceeds the maximum SVG size", svgWidth, svgHeight)
+	}
+
+	var svg bytes.Buffer
+	writeSVGHeader(&svg, svgWidth, svgHeight, fmt.Sprintf("Contributions per %s", granularityUnit(granularity)), multiMapSummary(grids))
+	opts.Theme.writeBackground(&svg, svgWidth, svgHeight)
+
+	textFill := contrastColor(opts.Theme.Background)
+	fontSize := layout.labelFontSize()
+	offsetY := 0
+	for i, grid := range grids {
+		if headerHeight > 0 {
+			svg.WriteString(fmt.Sprintf(`<text x="%d" y="%d" fill="%s" font-family="sans-serif" font-size="%dpx" font-weight="bold">%s</text>`, layout.CellMargin, offsetY+headerHeight-4, textFill, fontSize+2, escapeXML(grid.Label)))
+			svg.WriteString("\n")
+			offsetY += headerHeight
+		}
+		writeBarChart(&svg, charts[i], granularity, offsetY, opts)
+		_, height := barChartSize(len(charts[i]), granularity, layout)
+		offsetY += height
+	}
+	svg.WriteString("</svg>")
+	return finishSVG(svg.Bytes(), opts.Minify, opts.StripTooltips), nil
+}
+
+// writeBarChart draws one chart's bars, scaled to its largest period, with
+// the top edge of the chart at offsetY.
+func writeBarChart(svg *bytes.Buffer, periods []PeriodTotal, granularity string, offsetY int, opts MapOptions) {
+	layout := opts.Layout
+	textFill := contrastColor(opts.Theme.Background)
+	fontSize := layout.labelFontSize()
+	width := barWidth(granularity, layout)
+	plotTop := offsetY + layout.topMargin()
+	plotHeight := 7*(layout.CellSize+layout.CellMargin) + layout.CellMargin
+
+	maxCount := 0
+	for _, p := range periods {
+		maxCount = max(maxCount, p.Count)
+	}
+
+	for i, p := range periods {
+		x := layout.CellMargin + i*(width+layout.CellMargin)
+		barHeight := 0
+		if maxCount > 0 {
+			barHeight = p.Count * plotHeight / maxCount
+		}
+		// Empty periods keep a one-pixel stub so the time axis stays visible.
+		if barHeight < 1 {
+			barHeight = 1
+		}
+		svg.WriteString(fmt.Sprintf(`<rect x="%d" y="%d" width="%d" height="%d" fill="%s" aria-label="%s">
+  <title>%s</title>
+</rect>`, x, plotTop+plotHeight-barHeight, width, barHeight, getColor(p.Count, maxCount, opts.Theme), escapeXML(p.Title), escapeXML(p.Title)))
+		svg.WriteString("\n")
+		if granularity == granularityMonthly && p.Count > 0 {
+			svg.WriteString(fmt.Sprintf(`<text x="%d" y="%d" fill="%s" text-anchor="middle" font-family="sans-serif" font-size="%dpx">%s</text>`, x+width/2, plotTop+plotHeight-barHeight-2, textFill, fontSize, strconv.Itoa(p.Count)))
+			svg.WriteString("\n")
+		}
+		if p.Label != "" {
+			svg.WriteString(fmt.Sprintf(`<text x="%d" y="%d" fill="%s" font-family="sans-serif" font-size="%dpx">%s</text>`, x, plotTop+plotHeight+fontSize+2, textFill, fontSize, escapeXML(p.Label)))
+			svg.WriteString("\n")
+		}
+	}
+}
+
+// granularityUnit returns the period name used in chart titles.
+func granularityUnit(granularity string) string {
+	if granularity == granularityMonthly {
+		return "month"
+	}
+	return "week"
+}
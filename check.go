@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// =============================================================================
+// Credential Check (--check)
+// =============================================================================
+
+// checkCredentials verifies that the configured token is accepted by the
+// platform and reports who it belongs to and the remaining rate limit. It
+// returns an error when the check fails.
+func checkCredentials(ctx context.Context, cfg fetchConfig, out io.Writer) error {
+	return platforms[cfg.Platform].Check(ctx, cfg, out)
+}
+
+// checkGitHub runs the lightest possible GraphQL query: the token's login and
+// its rate limit.
+func checkGitHub(ctx context.Context, cfg fetchConfig, out io.Writer) error {
+	body, err := json.Marshal(map[string]string{"query": `query { viewer { login } rateLimit { limit remaining resetAt } }`})
+	if err != nil {
+		return err
+	}
+	resp, err := postGitHubGraphQL(ctx, cfg.GitHubURL, cfg.Token, body, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return withCode(statusCode(resp.StatusCode), fmt.Errorf("GitHub rejected the token: %s: %s", resp.Status, strings.TrimSpace(string(bodyBytes))))
+	}
+
+	var result struct {
+		Data struct {
+			Viewer struct {
+				Login string `json:"login"`
+			} `json:"viewer"`
+			RateLimit struct {
+				Limit     int    `json:"limit"`
+				Remaining int    `json:"remaining"`
+				ResetAt   string `json:"resetAt"`
+			} `json:"rateLimit"`
+		} `json:"data"`
+		Errors []GitHubGraphQLError `json:"errors"`
+	}
+	if err := decodeJSONResponse(resp, &result); err != nil {
+		return err
+	}
+	if len(result.Errors) > 0 {
+		return gitHubGraphQLErrors("", result.Errors)
+	}
+	fmt.Fprintf(out, "GitHub token OK: authenticated as %s at %s\n", result.Data.Viewer.Login, cfg.GitHubURL)
+	rl := result.Data.RateLimit
+	fmt.Fprintf(out, "Rate limit: %d of %d points remaining, resets at %s\n", rl.Remaining, rl.Limit, rl.ResetAt)
+	return nil
+}
+
+// checkGitea asks /api/v1/user who the token belongs to. Without a token only
+// reachability is checked, via /api/v1/version.
+func checkGitea(ctx context.Context, cfg fetchConfig, out io.Writer) error {
+	path := "/api/v1/user"
+	if cfg.Token == "" {
+		path = "/api/v1/version"
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", cfg.GiteaURL+path, nil)
+	if err != nil {
+		return err
+	}
+	if cfg.Token != "" {
+		req.Header.Set("Authorization", "token "+cfg.Token)
+	}
+	verboseLog.Printf("GET %s (token: %s)", cfg.GiteaURL+path, redactToken(cfg.Token))
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	verboseLog.Printf("Gitea responded %s", resp.Status)
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		if cfg.Token != "" {
+			return withCode(statusCode(resp.StatusCode), fmt.Errorf("%s rejected the token: %s: %s", cfg.GiteaURL, resp.Status, strings.TrimSpace(string(bodyBytes))))
+		}
+		return withCode(statusCode(resp.StatusCode), fmt.Errorf("%s is not reachable as a Gitea API: %s", cfg.GiteaURL, resp.Status))
+	}
+
+	if cfg.Token == "" {
+		fmt.Fprintf(out, "%s is reachable; no token configured, so only public activity is visible\n", cfg.GiteaURL)
+	} else {
+		var user struct {
+			Login string `json:"login"`
+		}
+		if err := decodeJSONResponse(resp, &user); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Token OK: authenticated as %s at %s\n", user.Login, cfg.GiteaURL)
+	}
+	// Gitea only sends rate-limit headers when a limit is configured.
+	if remaining := resp.Header.Get("X-RateLimit-Remaining"); remaining != "" {
+		fmt.Fprintf(out, "Rate limit: %s requests remaining, resets at %s\n", remaining, resp.Header.Get("X-RateLimit-Reset"))
+	} else {
+		fmt.Fprintln(out, "Rate limit: not reported by the server")
+	}
+	return nil
+}
+
+// checkBitbucket asks /user who the token belongs to.
+func checkBitbucket(ctx context.Context, cfg fetchConfig, out io.Writer) error {
+	if cfg.Token == "" {
+		return withCode(errCodeAuth, fmt.Errorf("no token configured; Bitbucket only shows public repositories without one"))
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", cfg.BitbucketURL+"/user", nil)
+	if err != nil {
+		return err
+	}
+	setBitbucketAuth(req, cfg.Token)
+	verboseLog.Printf("GET %s (token: %s)", cfg.BitbucketURL+"/user", redactToken(cfg.Token))
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	verboseLog.Printf("Bitbucket responded %s", resp.Status)
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return withCode(statusCode(resp.StatusCode), fmt.Errorf("Bitbucket rejected the token: %s: %s", resp.Status, strings.TrimSpace(string(bodyBytes))))
+	}
+	var user bitbucketUser
+	if err := decodeJSONResponse(resp, &user); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Bitbucket token OK: authenticated as %s at %s\n", user.Nickname, cfg.BitbucketURL)
+	if remaining := resp.Header.Get("X-RateLimit-Remaining"); remaining != "" {
+		fmt.Fprintf(out, "Rate limit: %s requests remaining\n", remaining)
+	} else {
+		fmt.Fprintln(out, "Rate limit: not reported by the server")
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// =============================================================================
+// Window Comparison (--compare-to)
+// =============================================================================
+
+// dateWindow is an inclusive range of days, given as FROM:TO to --compare-to
+// or --range.
+type dateWindow struct {
+	From time.Time
+	To   time.Time
+}
+
+// parseDateWindow parses a YYYY-MM-DD:YYYY-MM-DD window of any length. GitHub
+// serves at most a year of contributions per query, so longer windows are
+// fetched a year at a time; see fetchRange.
+func parseDateWindow(s string) (dateWindow, error) {
+	fromStr, toStr, ok := strings.Cut(s, ":")
+	if !ok {
+		return dateWindow{}, fmt.Errorf("%q is not FROM:TO, e.g. 2022-01-01:2022-12-31", s)
+	}
+	from, err := time.Parse("2006-01-02", strings.TrimSpace(fromStr))
+	if err != nil {
+		return dateWindow{}, fmt.Errorf("start %q is not a YYYY-MM-DD date", fromStr)
+	}
+	to, err := time.Parse("2006-01-02", strings.TrimSpace(toStr))
+	if err != nil {
+		return dateWindow{}, fmt.Errorf("end %q is not a YYYY-MM-DD date", toStr)
+	}
+	if to.Before(from) {
+		return dateWindow{}, fmt.Errorf("end %s is before start %s", to.Format("2006-01-02"), from.Format("2006-01-02"))
+	}
+	return dateWindow{From: from, To: to}, nil
+}
+
+// longerThanYear reports whether w needs more than one GitHub query.
+func (w dateWindow) longerThanYear() bool {
+	return !w.To.Before(w.From.AddDate(1, 0, 0))
+}
+
+func (w dateWindow) String() string {
+	return w.From.Format("2006-01-02") + " to " + w.To.Format("2006-01-02")
+}
+
+// countDelta is one count in the rendered window and in the compared one.
+type countDelta struct {
+	Current  int `json:"current"`
+	Previous int `json:"previous"`
+	Change   int `json:"change"`
+}
+
+func newCountDelta(current, previous int) countDelta {
+	return countDelta{Current: current, Previous: previous, Change: current - previous}
+}
+
+// monthDelta compares the contributions of one calendar month.
+type monthDelta struct {
+	Month string `json:"month"` // three-letter name, e.g. Jan
+	countDelta
+}
+
+// windowComparison is a user's rendered year set against a --compare-to
+// window: the totals, the four cross diagram types and each calendar month
+// both windows cover.
+type windowComparison struct {
+	Window       string       `json:"window"`
+	Total        countDelta   `json:"total"`
+	Commits      countDelta   `json:"commits"`
+	PullRequests countDelta   `json:"pull_requests"`
+	Issues       countDelta   `json:"issues"`
+	CodeReviews  countDelta   `json:"code_reviews"`
+	Months       []monthDelta `json:"months"`
+}
+
+// compareWindows compares grid with the contributions fetched for window.
+// Months are matched by name, so the partial first and last months of a
+// trailing year are counted together.
+func compareWindows(grid LabeledWeeks, window dateWindow, weeks Weeks, crossData CrossData, total int) windowComparison {
+	current, currentSeen := monthOfYearTotals(grid.Weeks)
+	previous, previousSeen := monthOfYearTotals(weeks)
+	c := windowComparison{
+		Window:       window.String(),
+		Total:        newCountDelta(grid.Total, total),
+		Commits:      newCountDelta(grid.CrossData.Commits, crossData.Commits),
+		PullRequests: newCountDelta(grid.CrossData.PullRequests, crossData.PullRequests),
+		Issues:       newCountDelta(grid.CrossData.Issues, crossData.Issues),
+		CodeReviews:  newCountDelta(grid.CrossData.CodeReviews, crossData.CodeReviews),
+		Months:       []monthDelta{},
+	}
+	for m := time.January; m <= time.December; m++ {
+		if currentSeen[m-1] && previousSeen[m-1] {
+			c.Months = append(c.Months, monthDelta{Month: m.String()[:3], countDelta: newCountDelta(current[m-1], previous[m-1])})
+		}
+	}
+	return c
+}
+
+// monthOfYearTotals sums the dated days of weeks by calendar month, January
+// first, and reports which months have any days at all.
+func monthOfYearTotals(weeks Weeks) (totals [12]int, seen [12]bool) {
+	for _, week := range weeks {
+		for _, day := range week {
+			t, err := time.Parse("2006-01-02", day.Date)
+			if err != nil {
+				continue
+			}
+			totals[t.Month()-1] += day.Count
+			seen[t.Month()-1] = true
+		}
+	}
+	return totals, seen
+}
+
+// printComparison writes c for the user named label, after printStats.
+func printComparison(w io.Writer, label string, c windowComparison) {
+	fmt.Fprintf(w, "Compared with %s for %s:\n", c.Window, label)
+	for _, row := range []struct {
+		name  string
+		delta countDelta
+	}{
+		{"Total contributions:", c.Total},
+		{"Commits:", c.Commits},
+		{"Pull requests:", c.PullRequests},
+		{"Issues:", c.Issues},
+		{"Code reviews:", c.CodeReviews},
+	} {
+		fmt.Fprintf(w, "  %-21s%d vs %d (%+d)\n", row.name, row.delta.Current, row.delta.Previous, row.delta.Change)
+	}
+	var improved []string
+	for _, m := range c.Months {
+		if m.Change > 0 {
+			improved = append(improved, fmt.Sprintf("%s (%+d)", m.Month, m.Change))
+		}
+	}
+	if len(improved) == 0 {
+		improved = []string{"none"}
+	}
+	fmt.Fprintf(w, "  %-21s%s\n", "Months improved:", strings.Join(improved, ", "))
+}
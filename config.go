@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// =============================================================================
+// Configuration File (--config)
+// =============================================================================
+
+// A configuration file sets option defaults, one option per line, named as on
+// the command line without the dashes:
+//
+//	# team defaults
+//	platform = "gitea"
+//	gitea-url: https://git.example.com
+//	cell-size = 14
+//	rounded = true
+//	color = ["background=#101010", "zero=#202020"]
+//
+// Both key = value and key: value are accepted, so flat TOML and YAML files
+// read the same; tables, nesting and multi-line values are not supported.
+// Values may be quoted, lists set repeatable options, and a leading ~/ is
+// expanded to the home directory. An option given on the command line
+// replaces the file's values for it, so a repeatable option such as --color
+// does not add to the file's list.
+
+// configOptionName matches the option names a configuration file may set.
+var configOptionName = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+
+// defaultConfigPaths are tried in order when --config is not given: config.yaml
+// or config.toml in the user's contribmap configuration directory. A file in
+// the current directory is only read when --config names it, so running in a
+// cloned repository cannot pick up options such as --github-url from it.
+func defaultConfigPaths() []string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return nil
+	}
+	return []string{filepath.Join(dir, "contribmap", "config.yaml"), filepath.Join(dir, "contribmap", "config.toml")}
+}
+
+// withConfigArgs returns args with the options of the configuration file
+// inserted after the program name, ahead of the command-line options so that
+// those take precedence; options the command line gives are left out of the
+// file's entirely. The file is the one named by --config, or the first
+// of defaultConfigPaths that exists; without either, or with --config "",
+// args are returned as is.
+func withConfigArgs(args []string) ([]string, error) {
+	filename, given := configFlag(args[1:])
+	if given {
+		if filename == "" {
+			return args, nil
+		}
+		filename = expandHome(filename)
+	} else {
+		for _, path := range defaultConfigPaths() {
+			if _, err := os.Stat(path); err == nil {
+				filename = path
+				break
+			}
+		}
+		if filename == "" {
+			return args, nil
+		}
+	}
+
+	configArgs, err := parseConfigFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	configArgs = withoutOptions(configArgs, commandLineOptions(args[1:]))
+	return append(append([]string{args[0]}, configArgs...), args[1:]...), nil
+}
+
+// configFlag returns the value of --config in the command-line options, and
+// whether it was given at all.
+func configFlag(args []string) (string, bool) {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		if value, ok := strings.CutPrefix(arg, "--config="); ok {
+			return value, true
+		}
+		if arg == "--config" && i+1 < len(args) {
+			return args[i+1], true
+		}
+	}
+	return "", false
+}
+
+// commandLineOptions returns the names of the options given in args.
+func commandLineOptions(args []string) map[string]bool {
+	names := make(map[string]bool)
+	for _, arg := range args {
+		if arg == "--" {
+			break
+		}
+		if name, ok := strings.CutPrefix(arg, "--"); ok {
+			name, _, _ = strings.Cut(name, "=")
+			names[name] = true
+		}
+	}
+	return names
+}
+
+// withoutOptions drops the options named in names from args as written by
+// parseConfigFile, where --name is always followed by an empty value.
+func withoutOptions(args []string, names map[string]bool) []string {
+	var kept []string
+	for i := 0; i < len(args); i++ {
+		name, _, hasValue := strings.Cut(strings.TrimPrefix(args[i], "--"), "=")
+		if names[name] {
+			if !hasValue {
+				i++
+			}
+			continue
+		}
+		kept = append(kept, args[i])
+		if !hasValue {
+			i++
+			kept = append(kept, args[i])
+		}
+	}
+	return kept
+}
+
+// parseConfigFile reads a configuration file into command-line options.
+func parseConfigFile(filename string) ([]string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var args []string
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || line == "---" {
+			continue
+		}
+		key, value, ok := cutConfigLine(line)
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected name = value or name: value", filename, lineNo)
+		}
+		if !configOptionName.MatchString(key) || key == "config" {
+			return nil, fmt.Errorf("%s:%d: %q is not an option name", filename, lineNo, key)
+		}
+		values, err := parseConfigValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %s: %v", filename, lineNo, key, err)
+		}
+		for _, v := range values {
+			// The parser rejects an empty --name= but takes --name "".
+			if v == "" {
+				args = append(args, "--"+key, "")
+				continue
+			}
+			args = append(args, "--"+key+"="+expandHome(v))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return args, nil
+}
+
+// cutConfigLine splits a line at the first = or :, whichever comes first, so
+// that a colon in a value such as a URL is kept.
+func cutConfigLine(line string) (key, value string, ok bool) {
+	i := strings.IndexAny(line, "=:")
+	if i <= 0 {
+		return "", "", false
+	}
+	return strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:]), true
+}
+
+// parseConfigValue returns the values of a scalar, a quoted string or a
+// [a, b] list. Unquoted values end at a # comment.
+func parseConfigValue(value string) ([]string, error) {
+	if inner, ok := strings.CutPrefix(value, "["); ok {
+		inner, ok = strings.CutSuffix(stripConfigComment(inner), "]")
+		if !ok {
+			return nil, errors.New("list is not closed with ]")
+		}
+		var values []string
+		for _, item := range splitConfigList(inner) {
+			v, err := parseConfigScalar(item)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+		}
+		return values, nil
+	}
+	v, err := parseConfigScalar(value)
+	if err != nil {
+		return nil, err
+	}
+	return []string{v}, nil
+}
+
+// parseConfigScalar unquotes a double- or single-quoted string, or returns an
+// unquoted value without its trailing comment.
+func parseConfigScalar(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	switch {
+	case strings.HasPrefix(value, `"`):
+		end := closingQuote(value)
+		if end < 0 {
+			return "", errors.New("string is not closed with \"")
+		}
+		return strconv.Unquote(value[:end+1])
+	case strings.HasPrefix(value, "'"):
+		end := strings.Index(value[1:], "'")
+		if end < 0 {
+			return "", errors.New("string is not closed with '")
+		}
+		return value[1 : end+1], nil
+	}
+	return stripConfigComment(value), nil
+}
+
+// closingQuote returns the index of the quote ending the double-quoted string
+// at the start of value, or -1.
+func closingQuote(value string) int {
+	for i := 1; i < len(value); i++ {
+		switch value[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+// stripConfigComment removes a # comment that follows whitespace, so colors
+// such as #ff0000 are kept.
+func stripConfigComment(value string) string {
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = value[:i]
+	}
+	return strings.TrimSpace(value)
+}
+
+// splitConfigList splits the inside of a list at the commas outside quotes.
+func splitConfigList(inner string) []string {
+	var items []string
+	start, quote := 0, byte(0)
+	for i := 0; i < len(inner); i++ {
+		switch c := inner[i]; {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ',':
+			items = append(items, inner[start:i])
+			start = i + 1
+		}
+	}
+	if last := strings.TrimSpace(inner[start:]); last != "" {
+		items = append(items, last)
+	}
+	return items
+}
+
+// expandHome replaces a leading ~/ with the user's home directory.
+func expandHome(path string) string {
+	rest, ok := strings.CutPrefix(path, "~/")
+	if !ok {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, rest)
+}
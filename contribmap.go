@@ -2,14 +2,34 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"encoding/xml"
+	"errors"
 	"fmt"
-	"io/ioutil"
+	"io"
+	"io/fs"
+	"log"
 	"math"
+	"mime"
 	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"text/template"
 	"time"
+	_ "time/tzdata" // --timezone works even without a system zoneinfo database
+	"unicode/utf8"
 
 	cli "github.com/jawher/mow.cli"
 )
@@ -18,16 +38,127 @@ import (
 // Shared Constants and Color Schemes
 // =============================================================================
 
-// Define the GitHub GraphQL API endpoint.
+// Define the default GitHub GraphQL API endpoint (overridable with --github-url).
 const githubGraphQLEndpoint = "https://api.github.com/graphql"
 
+// Number of events requested per page from the Gitea events API.
+const giteaPageLimit = 50
+
+// Event feed paths (relative to the instance URL) for Gitea and for Forgejo,
+// which Codeberg runs. The %s is the username.
+const (
+	giteaEventsPath   = "/api/v1/users/%s/events"
+	forgejoEventsPath = "/api/v1/users/%s/activities/feeds?only-performed-by=true"
+)
+
+// Default instance URL used for --platform codeberg.
+const codebergURL = "https://codeberg.org"
+
+// labeledWeekdays are the grid rows that get a weekday label; the others are
+// left unlabeled.
+var labeledWeekdays = []time.Weekday{time.Monday, time.Wednesday, time.Friday}
+
+// verboseLog receives diagnostic output; it discards everything unless
+// --verbose is given, in which case it writes to stderr.
+var verboseLog = log.New(io.Discard, "", 0)
+
+// queryDump receives the GitHub GraphQL requests as they are sent; it
+// discards them unless --dump-query is given, in which case it is stderr.
+var queryDump io.Writer = io.Discard
+
+// httpTransport is shared by every API request. Like the default transport it
+// takes its proxy from HTTP_PROXY, HTTPS_PROXY and NO_PROXY, unless --proxy
+// overrides it.
+var httpTransport = http.DefaultTransport.(*http.Transport).Clone()
+
+// version is the release the binary was built from, set at build time with
+// -ldflags "-X main.version=v1.2.3".
+var version = "dev"
+
+// commit and buildDate describe the build for --version and are set like
+// version, e.g. -X main.commit=$(git rev-parse --short HEAD)
+// -X main.buildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ). Left empty, they come
+// from the VCS stamp the go tool embeds, when there is one.
+var (
+	commit    = ""
+	buildDate = ""
+)
+
+// versionString describes the build for --version.
+func versionString() string {
+	rev, date := commit, buildDate
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && rev == "":
+				rev = setting.Value[:min(len(setting.Value), 12)]
+			case setting.Key == "vcs.time" && date == "":
+				date = setting.Value
+			}
+		}
+	}
+	if rev == "" {
+		rev = "unknown"
+	}
+	if date == "" {
+		date = "unknown"
+	}
+	return fmt.Sprintf("contribmap %s (commit %s, built %s, %s %s/%s)", version, rev, date, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+}
+
+// userAgent is sent with every API request; --user-agent replaces it.
+var userAgent = "contribmap/" + version
+
+// userAgentTransport sets userAgent on requests that carry no User-Agent of
+// their own.
+type userAgentTransport struct {
+	base http.RoundTripper
+}
+
+func (t userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("User-Agent") == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("User-Agent", userAgent)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// httpClient sends every API request. Tests and embedders can replace it,
+// e.g. with one whose transport answers from an httptest.Server.
+var httpClient = &http.Client{Transport: userAgentTransport{base: httpTransport}}
+
+// statusOut receives progress messages and statistics. It is stdout unless
+// generated output or --json-errors is written there, in which case it is
+// moved to stderr, or --quiet discards it.
+var statusOut io.Writer = os.Stdout
+
+// Dot placement formulas selectable with --cross-formula.
+const (
+	crossFormulaAxes     = "axes"
+	crossFormulaCentroid = "centroid"
+)
+
+// Color scales selectable with --scale, and the one --continuous selects.
+const (
+	scaleLinear     = "linear"
+	scaleQuantile   = "quantile"
+	scaleContinuous = "continuous"
+)
+
+// Lowest opacity of a nonzero cell with --continuous, so a single
+// contribution stays visible next to a busy day.
+const minContinuousOpacity = 0.1
+
 const (
 	// Background colors for the contribution map (which follows lightMode)
 	bgDark  = "#000000"
 	bgLight = "#ffffff"
 
-	// Number of nonzero color buckets for the map
-	bucketCount = 5
+	// Number of nonzero color buckets for the map, by default and the range
+	// --buckets accepts
+	defaultBucketCount = 5
+	minBucketCount     = 2
+	maxBucketCount     = 12
 
 	// Dark mode bucket colors (from darkest to brightest)
 	darkBucketColors0 = "#0B3D0B" // bucket 1 (lowest nonzero)
@@ -50,8 +181,8 @@ const (
 
 // Arrays to group bucket colors.
 var (
-	darkBucketColors  = [bucketCount]string{darkBucketColors0, darkBucketColors1, darkBucketColors2, darkBucketColors3, darkBucketColors4}
-	lightBucketColors = [bucketCount]string{lightBucketColors0, lightBucketColors1, lightBucketColors2, lightBucketColors3, lightBucketColors4}
+	darkBucketColors  = []string{darkBucketColors0, darkBucketColors1, darkBucketColors2, darkBucketColors3, darkBucketColors4}
+	lightBucketColors = []string{lightBucketColors0, lightBucketColors1, lightBucketColors2, lightBucketColors3, lightBucketColors4}
 )
 
 // =============================================================================
@@ -59,22 +190,34 @@ var (
 // =============================================================================
 
 const (
-	// Map layout
-	cellSize   = 12
-	cellMargin = 2
-	topMargin  = 20 // extra vertical space at the top for month labels
-
-	// Cross diagram dimensions and arm coordinates
-	crossSVGWidth  = 300
-	crossSVGHeight = 300
-	crossCenterX   = crossSVGWidth / 2
-	crossCenterY   = crossSVGHeight / 2
-
-	// Where to place the labels along the arms:
-	topY    = 50  // for Code Reviews (top)
-	bottomY = 250 // for Pull Requests (bottom)
-	leftX   = 50  // for Commits (left)
-	rightX  = 250 // for Issues (right)
+	// Map layout defaults (overridable with --cell-size and --cell-margin)
+	defaultCellSize   = 12
+	defaultCellMargin = 2
+
+	// Month labels use a 10px font and a 20px top margin at the default cell
+	// size; both scale proportionally with the chosen cell size.
+	baseLabelFontSize = 10
+	baseTopMargin     = 20
+
+	// Smallest month-label font, in pixels. Below it the labels would not be
+	// legible, and the top margin that scales with the font would be too
+	// short to hold them above the grid.
+	minLabelFontSize = 6
+
+	// Smallest font, in pixels, used for counts drawn inside cells
+	minCellLabelFontSize = 6
+
+	// Width in weeks of the placeholder drawn when there is no data at all
+	placeholderWeeks = 53
+
+	// Upper bound for either SVG dimension, guarding against overflow from
+	// huge cell sizes.
+	maxSVGDimension = 1 << 20
+
+	// Default width and height of the cross diagram, and the smallest that
+	// still fits its labels
+	defaultCrossSize = 300
+	minCrossSize     = 150
 )
 
 // =============================================================================
@@ -102,36 +245,203 @@ type GitHubContributionsCollection struct {
 	TotalPullRequestContributions       int                        `json:"totalPullRequestContributions"`
 	TotalIssueContributions             int                        `json:"totalIssueContributions"`
 	TotalPullRequestReviewContributions int                        `json:"totalPullRequestReviewContributions"`
+	RestrictedContributionsCount        int                        `json:"restrictedContributionsCount"`
 }
 
 type GitHubUser struct {
 	ContributionsCollection GitHubContributionsCollection `json:"contributionsCollection"`
 }
 
+// GitHubRateLimit is the rateLimit object requested alongside the data.
+type GitHubRateLimit struct {
+	Remaining int    `json:"remaining"`
+	ResetAt   string `json:"resetAt"`
+}
+
 type GitHubResponseData struct {
-	User GitHubUser `json:"user"`
+	User      GitHubUser       `json:"user"`
+	Viewer    GitHubViewer     `json:"viewer"`
+	RateLimit *GitHubRateLimit `json:"rateLimit"`
+}
+
+// GitHubViewer identifies the account the token belongs to.
+type GitHubViewer struct {
+	Login string `json:"login"`
+}
+
+type GitHubGraphQLError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
 }
 
 type GitHubGraphQLResponse struct {
-	Data GitHubResponseData `json:"data"`
+	Data   GitHubResponseData   `json:"data"`
+	Errors []GitHubGraphQLError `json:"errors"`
 }
 
 // --- Our Generic Types ---
 type ContributionDay struct {
-	Date  string
-	Count int
-	Color string
+	Date    string
+	Count   int
+	Color   string
+	Opacity float64 `json:"-"` // fill opacity with --continuous; 0 means opaque
+}
+
+// solidColor returns the day's color as seen over bg, for outputs that
+// cannot draw transparency.
+func (d ContributionDay) solidColor(bg string) string {
+	if d.Opacity <= 0 || d.Opacity >= 1 {
+		return d.Color
+	}
+	return blendColors(bg, d.Color, d.Opacity)
+}
+
+// opacityAttr returns the fill-opacity attribute of a cell, if it has one.
+func (d ContributionDay) opacityAttr() string {
+	if d.Opacity <= 0 || d.Opacity >= 1 {
+		return ""
+	}
+	return fmt.Sprintf(` fill-opacity="%0.3f"`, d.Opacity)
 }
 
 // Weeks is a slice of weeks; each week is a slice of 7 ContributionDay values.
 type Weeks [][]ContributionDay
 
-// MonthLabel holds an x coordinate and the label (three‑letter month).
+// LabeledWeeks pairs a contribution grid with the label drawn above it, used
+// when several users are rendered into one map.
+type LabeledWeeks struct {
+	Label     string
+	Weeks     Weeks
+	Total     int       // contributions in the year, for the --title header
+	CrossData CrossData // the user's breakdown, for --template
+}
+
+// MonthLabel holds the baseline position and the label (three‑letter month).
 type MonthLabel struct {
-	X     int
+	X, Y  int
 	Label string
 }
 
+// CrossOptions controls how generateCrossSVG renders the cross diagram.
+type CrossOptions struct {
+	Theme    Theme
+	Layout   CrossLayout
+	Formula  string     // crossFormulaAxes or crossFormulaCentroid; see crossDotPosition
+	Weights  *CrossData // multiplier per contribution type; nil counts each once
+	SortArms bool       // place the types by count, largest on top; see crossArms
+	Minify   bool       // strip the whitespace between elements
+	Gzip     bool       // gzip-compress the written file (--output svgz)
+}
+
+// CrossLayout holds the size and arm labels of the cross diagram. The labels
+// sit a sixth of the way in from the edges, so they move with the size.
+type CrossLayout struct {
+	Width  int
+	Height int
+	Labels CrossLabels
+}
+
+// CrossLabels are the names written on the four arms of the cross diagram.
+type CrossLabels struct {
+	Commits      string // left
+	PullRequests string // bottom
+	Issues       string // right
+	CodeReviews  string // top
+}
+
+// defaultCrossLabels are the arm labels unless --cross-label renames them.
+var defaultCrossLabels = CrossLabels{Commits: "Commits", PullRequests: "Pull Requests", Issues: "Issues", CodeReviews: "Code Reviews"}
+
+// validate rejects diagrams too small for the labels or too large to render.
+func (l CrossLayout) validate() error {
+	if l.Width < minCrossSize || l.Height < minCrossSize {
+		return fmt.Errorf("cross diagram must be at least %dx%d pixels, got %dx%d", minCrossSize, minCrossSize, l.Width, l.Height)
+	}
+	if l.Width > maxSVGDimension || l.Height > maxSVGDimension {
+		return fmt.Errorf("cross diagram must be at most %d pixels in either dimension", maxSVGDimension)
+	}
+	return nil
+}
+
+// centerX and centerY are where the dashed axes cross.
+func (l CrossLayout) centerX() int { return l.Width / 2 }
+func (l CrossLayout) centerY() int { return l.Height / 2 }
+
+// topY, bottomY, leftX and rightX place the labels along the arms: code
+// reviews at the top, pull requests at the bottom, commits on the left and
+// issues on the right.
+func (l CrossLayout) topY() int    { return l.Height / 6 }
+func (l CrossLayout) bottomY() int { return l.Height - l.Height/6 }
+func (l CrossLayout) leftX() int   { return l.Width / 6 }
+func (l CrossLayout) rightX() int  { return l.Width - l.Width/6 }
+
+// MapOptions controls how generateSVG renders the contribution map.
+type MapOptions struct {
+	LightMode       bool // selects text and cell-stroke colors
+	Theme           Theme
+	Layout          MapLayout
+	HighlightStreak bool                           // outline the longest streak and add a legend below the grid
+	Goal            int                            // when nonzero, mark the days with at least this many contributions
+	CellLabels      bool                           // draw the count inside each nonzero cell that fits it
+	ShadeWeekends   bool                           // tint the Saturday and Sunday rows behind the cells
+	Animate         time.Duration                  // when nonzero, fade the cells in week by week over this long
+	Minify          bool                           // strip the whitespace between elements
+	StripTooltips   bool                           // leave out the per-cell <title> tooltips
+	Template        *template.Template             // a --template drawing a single map instead of builtinMapTemplate
+	AutoLight       *Theme                         // with --mode auto, the palette switched to for a light color scheme; see writeAutoModeStyle
+	DayLink         func(user, date string) string // with --link, the page a nonzero cell opens; nil leaves cells unlinked
+	Gzip            bool                           // gzip-compress the written file (--output svgz)
+	RichTooltips    bool                           // name the weekday in each day's tooltip; see dayTooltip
+	Window          *dateWindow                    // with --range, the days mapped, which the --title header names instead of the last year
+}
+
+// dayTooltip returns the tooltip and aria-label of a map cell, such as
+// "2025-11-30: 2 contributions", or "Sun 2025-11-30: 2 contributions" with
+// RichTooltips.
+func (o MapOptions) dayTooltip(date string, count int) string {
+	tooltip := fmt.Sprintf("%s: %d contributions", date, count)
+	if o.RichTooltips {
+		if t, err := time.Parse("2006-01-02", date); err == nil {
+			tooltip = o.Layout.Locale.weekday(t.Weekday()) + " " + tooltip
+		}
+	}
+	return tooltip
+}
+
+// autoClass returns a class attribute naming an element the --mode auto
+// style recolors, or nothing without --mode auto.
+func (o MapOptions) autoClass(name string) string {
+	if o.AutoLight == nil {
+		return ""
+	}
+	return fmt.Sprintf(` class="%s"`, name)
+}
+
+// writeAutoModeStyle writes the <style> that gives a --mode auto map the
+// light palette when the viewer prefers a light color scheme. The elements
+// carry the dark palette as attributes, which CSS rules take precedence over,
+// so viewers without CSS show the dark map.
+func writeAutoModeStyle(svg *bytes.Buffer, opts MapOptions) error {
+	if opts.AutoLight == nil {
+		return nil
+	}
+	return builtinMapTemplate.ExecuteTemplate(svg, "auto-style", *opts.AutoLight)
+}
+
+// MapLayout holds the geometry of the contribution map grid.
+type MapLayout struct {
+	CellSize      int
+	CellMargin    int
+	WeekdayLabels bool         // reserve a left gutter for Mon/Wed/Fri labels
+	Rounded       bool         // draw cells as rounded rectangles
+	Wrap          int          // rows to wrap the weeks into; 0 or 1 keeps a single strip
+	Title         bool         // reserve a header above the grid for the yearly total
+	WeekStart     time.Weekday // weekday of the top row: Sunday (as on GitHub) or Monday
+	Locale        labelLocale  // month and weekday names; the zero value is English
+
+	weeksPerRow int // set by forWeeks when wrapping; 0 means one strip
+}
+
 // CrossData holds the totals for the four contribution types.
 type CrossData struct {
 	Commits      int
@@ -140,11 +450,249 @@ type CrossData struct {
 	CodeReviews  int
 }
 
+// add returns the field-wise sum of two CrossData values.
+func (c CrossData) add(other CrossData) CrossData {
+	return CrossData{
+		Commits:      c.Commits + other.Commits,
+		PullRequests: c.PullRequests + other.PullRequests,
+		Issues:       c.Issues + other.Issues,
+		CodeReviews:  c.CodeReviews + other.CodeReviews,
+	}
+}
+
+// weighted returns c with each field multiplied by the matching field of w,
+// or c itself when w is nil.
+func (c CrossData) weighted(w *CrossData) CrossData {
+	if w == nil {
+		return c
+	}
+	return CrossData{
+		Commits:      c.Commits * w.Commits,
+		PullRequests: c.PullRequests * w.PullRequests,
+		Issues:       c.Issues * w.Issues,
+		CodeReviews:  c.CodeReviews * w.CodeReviews,
+	}
+}
+
+// parseCrossWeights parses --weight values such as "commits=1,reviews=3" into
+// multipliers for CrossData.weighted. Types that are not named keep weight 1.
+func parseCrossWeights(specs []string) (*CrossData, error) {
+	weights := CrossData{Commits: 1, PullRequests: 1, Issues: 1, CodeReviews: 1}
+	for _, spec := range specs {
+		for _, pair := range strings.Split(spec, ",") {
+			if pair = strings.TrimSpace(pair); pair == "" {
+				continue
+			}
+			key, value, ok := strings.Cut(pair, "=")
+			n, err := strconv.Atoi(strings.TrimSpace(value))
+			if !ok || err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid weight %q: expected type=N with N a whole number of 0 or more", pair)
+			}
+			switch strings.ToLower(strings.TrimSpace(key)) {
+			case "commits":
+				weights.Commits = n
+			case "prs", "pull-requests", "pullrequests":
+				weights.PullRequests = n
+			case "issues":
+				weights.Issues = n
+			case "reviews", "code-reviews", "codereviews":
+				weights.CodeReviews = n
+			default:
+				return nil, fmt.Errorf("invalid weight type %q: use commits, prs, issues or reviews", key)
+			}
+		}
+	}
+	if weights == (CrossData{}) {
+		return nil, errors.New("at least one contribution type needs a nonzero weight")
+	}
+	return &weights, nil
+}
+
+// Arm positions of the cross diagram, indexing the array crossArms returns.
+const (
+	crossArmTop = iota
+	crossArmBottom
+	crossArmLeft
+	crossArmRight
+)
+
+// crossArm is one contribution type on the cross diagram, with the position
+// of its label.
+type crossArm struct {
+	Label string
+	Count int
+	Perc  float64 // share of the total, in percent
+	X, Y  int
+}
+
+// crossArms returns the arms of the cross diagram of the (already weighted)
+// crossData, indexed by position. By default code reviews are on top, pull
+// requests at the bottom, commits on the left and issues on the right. With
+// opts.SortArms the types are placed by count instead: the largest on top and
+// the others clockwise from it, right, bottom and left; ties keep the default
+// order.
+func crossArms(crossData CrossData, opts CrossOptions) [4]crossArm {
+	layout := opts.Layout
+	commitsPerc, prPerc, issuesPerc, codeReviewsPerc := crossData.percentages()
+	types := []crossArm{
+		{Label: layout.Labels.CodeReviews, Count: crossData.CodeReviews, Perc: codeReviewsPerc},
+		{Label: layout.Labels.PullRequests, Count: crossData.PullRequests, Perc: prPerc},
+		{Label: layout.Labels.Commits, Count: crossData.Commits, Perc: commitsPerc},
+		{Label: layout.Labels.Issues, Count: crossData.Issues, Perc: issuesPerc},
+	}
+	positions := []int{crossArmTop, crossArmBottom, crossArmLeft, crossArmRight}
+	if opts.SortArms {
+		sort.SliceStable(types, func(i, j int) bool { return types[i].Count > types[j].Count })
+		positions = []int{crossArmTop, crossArmRight, crossArmBottom, crossArmLeft}
+	}
+
+	var arms [4]crossArm
+	for i, arm := range types {
+		arms[positions[i]] = arm
+	}
+	arms[crossArmTop].X, arms[crossArmTop].Y = layout.centerX(), layout.topY()
+	arms[crossArmBottom].X, arms[crossArmBottom].Y = layout.centerX(), layout.bottomY()
+	arms[crossArmLeft].X, arms[crossArmLeft].Y = layout.leftX(), layout.centerY()
+	arms[crossArmRight].X, arms[crossArmRight].Y = layout.rightX(), layout.centerY()
+	return arms
+}
+
+// parseCrossLabels parses --cross-label values such as "commits=Commits" into
+// arm labels, naming the types as --weight does. Types that are not named keep
+// their English label.
+func parseCrossLabels(specs []string) (CrossLabels, error) {
+	labels := defaultCrossLabels
+	for _, spec := range specs {
+		key, value, ok := strings.Cut(spec, "=")
+		if !ok || strings.TrimSpace(value) == "" {
+			return CrossLabels{}, fmt.Errorf("invalid label %q: expected type=text", spec)
+		}
+		value = strings.TrimSpace(value)
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "commits":
+			labels.Commits = value
+		case "prs", "pull-requests", "pullrequests":
+			labels.PullRequests = value
+		case "issues":
+			labels.Issues = value
+		case "reviews", "code-reviews", "codereviews":
+			labels.CodeReviews = value
+		default:
+			return CrossLabels{}, fmt.Errorf("invalid label type %q: use commits, prs, issues or reviews", key)
+		}
+	}
+	return labels, nil
+}
+
 // --- Gitea Event Type ---
 // For Gitea we expect the events API to return at least these fields.
+// Forgejo (and so Codeberg) activity feeds name them op_type and created
+// instead; both spellings are decoded and kind/createdAt pick whichever is set.
 type GiteaEvent struct {
 	Type      string `json:"type"`
 	CreatedAt string `json:"created_at"`
+	OpType    string `json:"op_type"`
+	Created   string `json:"created"`
+}
+
+// kind returns the lower-cased event type from either payload shape.
+func (e GiteaEvent) kind() string {
+	if e.OpType != "" {
+		return strings.ToLower(e.OpType)
+	}
+	return strings.ToLower(e.Type)
+}
+
+// Cross diagram categories an event type can count toward.
+const (
+	eventOther = iota // daily total only
+	eventCommit
+	eventPullRequest
+	eventIssue
+	eventCodeReview
+)
+
+// giteaEventCategories classifies the lower-cased event types of Gitea's
+// events API (GitHub-style names) and of the Gitea/Forgejo activity feed (op
+// types). Every event counts toward its day; the category decides which cross
+// diagram arm, if any, it also counts toward:
+//
+//	commits        commit_repo, pushevent
+//	pull requests  create_pull_request, merge_pull_request, pullrequestevent
+//	issues         create_issue, close_issue, reopen_issue, comment_issue,
+//	               issueevent, issuestatechangeevent, issuecommentevent
+//	code reviews   approve_pull_request, reject_pull_request, comment_pull,
+//	               pullrequestreviewevent, pullrequestcommentevent
+//	other          repository, tag, branch, release, star, watch and mirror
+//	               events, and pull request state changes
+//
+// Types missing from the table are treated as other and logged with --verbose.
+var giteaEventCategories = map[string]int{
+	"commit_repo": eventCommit,
+	"pushevent":   eventCommit,
+
+	"create_pull_request": eventPullRequest,
+	"merge_pull_request":  eventPullRequest,
+	"pullrequestevent":    eventPullRequest,
+
+	"create_issue":          eventIssue,
+	"close_issue":           eventIssue,
+	"reopen_issue":          eventIssue,
+	"comment_issue":         eventIssue,
+	"issueevent":            eventIssue,
+	"issuestatechangeevent": eventIssue,
+	"issuecommentevent":     eventIssue,
+
+	"approve_pull_request":    eventCodeReview,
+	"reject_pull_request":     eventCodeReview,
+	"comment_pull":            eventCodeReview,
+	"pullrequestreviewevent":  eventCodeReview,
+	"pullrequestcommentevent": eventCodeReview,
+
+	"create_repo":                   eventOther,
+	"rename_repo":                   eventOther,
+	"transfer_repo":                 eventOther,
+	"star_repo":                     eventOther,
+	"watch_repo":                    eventOther,
+	"push_tag":                      eventOther,
+	"delete_tag":                    eventOther,
+	"delete_branch":                 eventOther,
+	"publish_release":               eventOther,
+	"mirror_sync_push":              eventOther,
+	"mirror_sync_create":            eventOther,
+	"mirror_sync_delete":            eventOther,
+	"close_pull_request":            eventOther,
+	"reopen_pull_request":           eventOther,
+	"auto_merge_pull_request":       eventOther,
+	"pull_review_dismissed":         eventOther,
+	"pull_request_ready_for_review": eventOther,
+	"createevent":                   eventOther,
+	"deleteevent":                   eventOther,
+	"releaseevent":                  eventOther,
+	"forkevent":                     eventOther,
+	"watchevent":                    eventOther,
+}
+
+// addEvent counts one event of the given category from giteaEventCategories.
+func (c *CrossData) addEvent(category int) {
+	switch category {
+	case eventCommit:
+		c.Commits++
+	case eventPullRequest:
+		c.PullRequests++
+	case eventIssue:
+		c.Issues++
+	case eventCodeReview:
+		c.CodeReviews++
+	}
+}
+
+// createdAt returns the event timestamp from either payload shape.
+func (e GiteaEvent) createdAt() string {
+	if e.Created != "" {
+		return e.Created
+	}
+	return e.CreatedAt
 }
 
 // =============================================================================
@@ -152,28 +700,69 @@ type GiteaEvent struct {
 // =============================================================================
 
 // getColor returns a hex color string for a given day's contribution count.
-// It splits the range 1..maxCount equally into bucketCount buckets. The lowest
+// It splits the range 1..maxCount into one equally wide bucket per theme
+// bucket color, so a
+// day equal to maxCount always lands in the brightest bucket. The lowest
 // bucket gets the darkest green and the highest gets the lightest green.
-func getColor(count int, maxCount int, lightMode bool) string {
-	if count == 0 {
-		if lightMode {
-			return zeroColorLight
+func getColor(count int, maxCount int, theme Theme) string {
+	if count <= 0 {
+		return theme.Zero
+	}
+	if maxCount < 1 {
+		maxCount = 1
+	}
+	buckets := len(theme.Buckets)
+	// ceil(count * buckets / maxCount) - 1, using integer arithmetic
+	bucketIndex := (count*buckets+maxCount-1)/maxCount - 1
+	if bucketIndex >= buckets {
+		bucketIndex = buckets - 1
+	}
+	return theme.Buckets[bucketIndex]
+}
+
+// getQuantileColor returns a hex color string for a given day's contribution
+// count using precomputed quantile thresholds (see quantileThresholds). A count
+// lands in the first bucket whose upper threshold it does not exceed.
+func getQuantileColor(count int, thresholds []int, theme Theme) string {
+	if count <= 0 {
+		return theme.Zero
+	}
+	bucketIndex := 0
+	for _, threshold := range thresholds {
+		if count > threshold {
+			bucketIndex++
 		}
-		return zeroColorDark
 	}
-	// Compute bucket width (ensuring at least 1)
-	bucketWidth := int(math.Ceil(float64(maxCount-1) / float64(bucketCount)))
-	if bucketWidth < 1 {
-		bucketWidth = 1
+	if bucketIndex >= len(theme.Buckets) {
+		bucketIndex = len(theme.Buckets) - 1
+	}
+	return theme.Buckets[bucketIndex]
+}
+
+// quantileThresholds returns the buckets-1 upper thresholds (the 20th, 40th,
+// 60th and 80th percentiles for five buckets) of the nonzero counts in weeks.
+func quantileThresholds(weeks Weeks, buckets int) []int {
+	var counts []int
+	for _, week := range weeks {
+		for _, day := range week {
+			if day.Count > 0 {
+				counts = append(counts, day.Count)
+			}
+		}
 	}
-	bucketIndex := (count - 1) / bucketWidth
-	if bucketIndex >= bucketCount {
-		bucketIndex = bucketCount - 1
+	if len(counts) == 0 {
+		return nil
 	}
-	if lightMode {
-		return lightBucketColors[bucketIndex]
+	sort.Ints(counts)
+	thresholds := make([]int, buckets-1)
+	for i := range thresholds {
+		rank := (i + 1) * len(counts) / buckets
+		if rank > 0 {
+			rank--
+		}
+		thresholds[i] = counts[rank]
 	}
-	return darkBucketColors[bucketIndex]
+	return thresholds
 }
 
 // =============================================================================
@@ -182,15 +771,24 @@ func getColor(count int, maxCount int, lightMode bool) string {
 
 // fetchGitHubContributions queries GitHub’s GraphQL API for both the daily
 // contributions (for the map) and the breakdown totals (for the cross diagram).
-func fetchGitHubContributions(username, token string, lightMode bool) (Weeks, CrossData, error) {
+// The endpoint is the GraphQL URL, e.g. githubGraphQLEndpoint or a GitHub
+// Enterprise Server's https://ghe.example.com/api/graphql.
+// It also returns the calendar's totalContributions. A nil window fetches
+// GitHub's default, the trailing year.
+// A nonempty ifNoneMatch is sent as If-None-Match, and a 304 answer to it is
+// returned as errNotModified; otherwise the response's ETag is returned with
+// the data, for the next revalidation.
+// Canceling ctx aborts the request and returns the context's error.
+func fetchGitHubContributions(ctx context.Context, endpoint, username, token string, window *dateWindow, ifNoneMatch string, lightMode bool) (Weeks, CrossData, int, string, error) {
 	query := `
-	query($login: String!) {
+	query($login: String!, $from: DateTime, $to: DateTime) {
 	  user(login: $login) {
-	    contributionsCollection {
+	    contributionsCollection(from: $from, to: $to) {
 	      totalCommitContributions
 	      totalPullRequestContributions
 	      totalIssueContributions
 	      totalPullRequestReviewContributions
+	      restrictedContributionsCount
 	      contributionCalendar {
 	        totalContributions
 	        weeks {
@@ -202,41 +800,74 @@ func fetchGitHubContributions(username, token string, lightMode bool) (Weeks, Cr
 	      }
 	    }
 	  }
+	  viewer {
+	    login
+	  }
+	  rateLimit {
+	    remaining
+	    resetAt
+	  }
 	}`
 	variables := map[string]interface{}{
 		"login": username,
 	}
+	// Omitted variables leave the arguments unset rather than null.
+	if window != nil {
+		variables["from"] = window.From.Format(time.RFC3339)
+		variables["to"] = window.To.Add(24*time.Hour - time.Second).Format(time.RFC3339)
+	}
 	reqBody := map[string]interface{}{
 		"query":     query,
 		"variables": variables,
 	}
 	reqBodyBytes, err := json.Marshal(reqBody)
 	if err != nil {
-		return nil, CrossData{}, err
-	}
-
-	req, err := http.NewRequest("POST", githubGraphQLEndpoint, bytes.NewBuffer(reqBodyBytes))
-	if err != nil {
-		return nil, CrossData{}, err
+		return nil, CrossData{}, 0, "", err
 	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "bearer "+token)
+	dumpGitHubQuery(queryDump, endpoint, token, query, variables)
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := postGitHubGraphQL(ctx, endpoint, token, reqBodyBytes, ifNoneMatch)
 	if err != nil {
-		return nil, CrossData{}, err
+		if ctx.Err() != nil {
+			return nil, CrossData{}, 0, "", ctx.Err()
+		}
+		return nil, CrossData{}, 0, "", err
 	}
 	defer resp.Body.Close()
 
+	remaining, reset, hasRateLimit := gitHubRateLimitHeaders(resp.Header)
+	if hasRateLimit {
+		verboseLog.Printf("GitHub rate limit: %d points remaining, resets at %s", remaining, reset.Format(time.RFC3339))
+		gitHubRateLimit.observe(remaining, reset)
+	}
+	if resp.StatusCode == http.StatusNotModified && ifNoneMatch != "" {
+		return nil, CrossData{}, 0, "", errNotModified
+	}
+	etag := resp.Header.Get("ETag")
 	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := ioutil.ReadAll(resp.Body)
-		return nil, CrossData{}, fmt.Errorf("GitHub API error: %s", string(bodyBytes))
+		if hasRateLimit && remaining == 0 {
+			return nil, CrossData{}, 0, "", gitHubRateLimitError(reset)
+		}
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, CrossData{}, 0, "", withCode(statusCode(resp.StatusCode), fmt.Errorf("GitHub API error: %s", string(bodyBytes)))
 	}
 
 	var gqlResp GitHubGraphQLResponse
-	if err := json.NewDecoder(resp.Body).Decode(&gqlResp); err != nil {
-		return nil, CrossData{}, err
+	if err := decodeJSONResponse(resp, &gqlResp); err != nil {
+		return nil, CrossData{}, 0, "", err
+	}
+	// GraphQL reports problems such as unknown users or missing token scopes
+	// with a 200 status and an errors array instead of data.
+	if len(gqlResp.Errors) > 0 {
+		for _, e := range gqlResp.Errors {
+			if e.Type == "RATE_LIMITED" {
+				return nil, CrossData{}, 0, "", gitHubRateLimitError(reset)
+			}
+		}
+		return nil, CrossData{}, 0, "", gitHubGraphQLErrors(username, gqlResp.Errors)
+	}
+	if rl := gqlResp.Data.RateLimit; rl != nil {
+		verboseLog.Printf("GitHub GraphQL rateLimit: %d points remaining, resets at %s", rl.Remaining, rl.ResetAt)
 	}
 
 	var weeks Weeks
@@ -252,8 +883,15 @@ func fetchGitHubContributions(username, token string, lightMode bool) (Weeks, Cr
 		}
 		weeks = append(weeks, days)
 	}
+	verboseLog.Printf("Parsed %d weeks for GitHub user %s", len(weeks), username)
+	verboseLog.Printf("GitHub reports %d contributions in the calendar for %s", gqlResp.Data.User.ContributionsCollection.ContributionCalendar.TotalContributions, username)
 
 	cc := gqlResp.Data.User.ContributionsCollection
+	// Private contributions only reach the calendar when the user opts in on
+	// their profile; otherwise GitHub counts them here instead.
+	if cc.RestrictedContributionsCount > 0 && strings.EqualFold(gqlResp.Data.Viewer.Login, username) {
+		verboseLog.Printf("Warning: %d private contributions of %s are excluded from the calendar; enable \"Private contributions\" in the GitHub profile settings to include them", cc.RestrictedContributionsCount, username)
+	}
 	crossData := CrossData{
 		Commits:      cc.TotalCommitContributions,
 		PullRequests: cc.TotalPullRequestContributions,
@@ -261,366 +899,2570 @@ func fetchGitHubContributions(username, token string, lightMode bool) (Weeks, Cr
 		CodeReviews:  cc.TotalPullRequestReviewContributions,
 	}
 
-	return weeks, crossData, nil
+	return weeks, crossData, cc.ContributionCalendar.TotalContributions, etag, nil
 }
 
-// fetchGiteaContributions queries Gitea’s events API for the given user,
-// aggregates daily totals (for the map) and also computes a breakdown (for the cross diagram).
-func fetchGiteaContributions(username, baseURL string, lightMode bool) (Weeks, CrossData, error) {
-	url := fmt.Sprintf("%s/api/v1/users/%s/events", baseURL, username)
-	resp, err := http.Get(url)
+// loadTLSConfig returns the TLS settings for API requests: the system roots
+// plus the certificates in the PEM file caCert, if given, and no verification
+// at all with insecure.
+func loadTLSConfig(caCert string, insecure bool) (*tls.Config, error) {
+	config := &tls.Config{InsecureSkipVerify: insecure}
+	if caCert == "" {
+		return config, nil
+	}
+	pem, err := os.ReadFile(caCert)
 	if err != nil {
-		return nil, CrossData{}, err
+		return nil, err
 	}
-	defer resp.Body.Close()
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("%s contains no PEM certificates", caCert)
+	}
+	config.RootCAs = pool
+	return config, nil
+}
 
+// fetchGitHubViewerLogin returns the login of the account token belongs to,
+// for runs without --user.
+func fetchGitHubViewerLogin(ctx context.Context, endpoint, token string) (string, error) {
+	reqBodyBytes, err := json.Marshal(map[string]string{"query": `query { viewer { login } }`})
+	if err != nil {
+		return "", err
+	}
+	resp, err := postGitHubGraphQL(ctx, endpoint, token, reqBodyBytes, "")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := ioutil.ReadAll(resp.Body)
-		return nil, CrossData{}, fmt.Errorf("Gitea API error: %s", string(bodyBytes))
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return "", withCode(statusCode(resp.StatusCode), fmt.Errorf("GitHub API error: %s", string(bodyBytes)))
 	}
 
-	var events []GiteaEvent
-	if err := json.NewDecoder(resp.Body).Decode(&events); err != nil {
-		return nil, CrossData{}, err
+	var gqlResp GitHubGraphQLResponse
+	if err := decodeJSONResponse(resp, &gqlResp); err != nil {
+		return "", err
 	}
+	if len(gqlResp.Errors) > 0 {
+		return "", gitHubGraphQLErrors("", gqlResp.Errors)
+	}
+	if gqlResp.Data.Viewer.Login == "" {
+		return "", errors.New("GitHub returned no viewer login")
+	}
+	return gqlResp.Data.Viewer.Login, nil
+}
 
-	contributionsMap := make(map[string]int)
-	var crossData CrossData
+// GitHub answers 202 Accepted while it is still computing statistics. Such
+// responses are retried up to githubComputingRetries times, waiting
+// githubComputingDelay before the first retry and twice as long each time after.
+const (
+	githubComputingRetries = 4
+	githubComputingDelay   = time.Second
+)
+
+// dumpGitHubQuery writes the query and variables of a GraphQL request to w for
+// --dump-query, in a form that can be pasted into GitHub's GraphQL Explorer.
+// The token is only described, never written.
+func dumpGitHubQuery(w io.Writer, endpoint, token, query string, variables map[string]interface{}) {
+	if w == io.Discard {
+		return
+	}
+	vars, err := json.MarshalIndent(variables, "", "  ")
+	if err != nil {
+		return
+	}
+	// The query is indented to sit in the Go source; drop that indentation.
+	query = strings.TrimSpace(strings.ReplaceAll(query, "\n\t", "\n"))
+	fmt.Fprintf(w, "# POST %s (token: %s)\n# Query:\n%s\n# Variables:\n%s\n", endpoint, redactToken(token), query, vars)
+}
 
-	// Classify events (adjust these mappings as needed)
-	for _, event := range events {
-		eventType := strings.ToLower(event.Type)
-		t, err := time.Parse(time.RFC3339, event.CreatedAt)
+// postGitHubGraphQL posts the GraphQL request body to endpoint and returns the
+// response, retrying while GitHub answers 202 Accepted. A nonempty ifNoneMatch
+// is sent as If-None-Match, to which GitHub may answer 304 Not Modified. The
+// caller closes the response body.
+func postGitHubGraphQL(ctx context.Context, endpoint, token string, body []byte, ifNoneMatch string) (*http.Response, error) {
+	delay := githubComputingDelay
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(body))
 		if err != nil {
-			continue
+			return nil, err
 		}
-		dateStr := t.Format("2006-01-02")
-		contributionsMap[dateStr]++
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "bearer "+token)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		verboseLog.Printf("POST %s (token: %s)", endpoint, redactToken(token))
 
-		switch eventType {
-		case "pushevent":
-			crossData.Commits++
-		case "pullrequestevent":
-			crossData.PullRequests++
-		case "issuestatechangeevent", "issueevent":
-			crossData.Issues++
-		case "pullrequestcommentevent", "pullrequestreviewevent":
-			crossData.CodeReviews++
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		verboseLog.Printf("GitHub responded %s", resp.Status)
+		if resp.StatusCode != http.StatusAccepted {
+			return resp, nil
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if attempt == githubComputingRetries {
+			return nil, fmt.Errorf("GitHub was still computing the contributions after %d attempts; try again in a minute", attempt+1)
+		}
+		verboseLog.Printf("GitHub is still computing the contributions; retrying in %s", delay)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
 		}
+		delay *= 2
+	}
+}
+
+// validateEndpointURL checks that raw is an absolute http(s) URL with a host.
+func validateEndpointURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("%q is not a valid URL: %w", raw, err)
 	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%q must start with http:// or https://", raw)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("%q has no host", raw)
+	}
+	return nil
+}
 
-	// Build the Weeks grid covering roughly the past year.
-	today := time.Now()
-	startDate := today.AddDate(0, 0, -364)
-	weekday := startDate.Weekday()
-	startDate = startDate.AddDate(0, 0, -int(weekday))
+// normalizeBaseURL returns the instance base URL raw in the form API paths are
+// appended to: https:// is assumed when no scheme is given, and trailing
+// slashes are dropped. Values that cannot be an http(s) base URL, such as ones
+// with a query, are rejected.
+func normalizeBaseURL(raw string) (string, error) {
+	trimmed := strings.TrimSpace(raw)
+	if trimmed == "" {
+		return "", errors.New("the URL is empty")
+	}
+	if !strings.Contains(trimmed, "://") {
+		trimmed = "https://" + trimmed
+	}
+	if err := validateEndpointURL(trimmed); err != nil {
+		return "", err
+	}
+	u, _ := url.Parse(trimmed)
+	if u.RawQuery != "" || u.Fragment != "" || strings.HasSuffix(trimmed, "?") {
+		return "", fmt.Errorf("%q must not have a query or fragment", raw)
+	}
+	if u.User != nil {
+		return "", fmt.Errorf("%q must not contain credentials; use --token", raw)
+	}
+	u.Path, u.RawPath = strings.TrimRight(u.Path, "/"), strings.TrimRight(u.RawPath, "/")
+	return u.String(), nil
+}
 
-	var weeks Weeks
-	var currentWeek []ContributionDay
-	currentDate := startDate
-	for !currentDate.After(today) {
-		dateStr := currentDate.Format("2006-01-02")
-		count := contributionsMap[dateStr]
-		currentWeek = append(currentWeek, ContributionDay{
-			Date:  dateStr,
-			Count: count,
-			Color: "",
-		})
-		if currentDate.Weekday() == time.Saturday {
-			weeks = append(weeks, currentWeek)
-			currentWeek = []ContributionDay{}
-		}
-		currentDate = currentDate.AddDate(0, 0, 1)
+// gitHubRateLimitHeaders reads the X-RateLimit-Remaining and X-RateLimit-Reset
+// headers; ok is false when the response did not include them.
+func gitHubRateLimitHeaders(h http.Header) (remaining int, reset time.Time, ok bool) {
+	remaining, err := strconv.Atoi(h.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return 0, time.Time{}, false
 	}
-	if len(currentWeek) > 0 {
-		for len(currentWeek) < 7 {
-			currentWeek = append(currentWeek, ContributionDay{
-				Date:  "",
-				Count: 0,
-				Color: "",
-			})
+	if seconds, err := strconv.ParseInt(h.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		reset = time.Unix(seconds, 0)
+	}
+	return remaining, reset, true
+}
+
+// gitHubRateLimitError reports an exhausted rate limit and when it resets; a
+// zero reset means the time is unknown.
+func gitHubRateLimitError(reset time.Time) error {
+	if reset.IsZero() {
+		return withCode(errCodeRateLimit, errors.New("GitHub API rate limit exceeded; try again later"))
+	}
+	return withCode(errCodeRateLimit, fmt.Errorf("GitHub API rate limit exceeded; it resets at %s (in %s)", reset.Local().Format(time.RFC1123), time.Until(reset).Round(time.Second)))
+}
+
+// maxBodySnippet is how much of an unexpected response body errors quote.
+const maxBodySnippet = 200
+
+// decodeJSONResponse decodes the body of a successful response into v. A body
+// that is not JSON, such as a proxy's login page, is reported with its
+// Content-Type and the start of the body instead of a bare decode error. A
+// missing Content-Type is given the benefit of the doubt.
+func decodeJSONResponse(resp *http.Response, v interface{}) error {
+	if err := checkJSONResponse(resp); err != nil {
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// checkJSONResponse is the Content-Type check of decodeJSONResponse, for
+// callers that decode the body themselves.
+func checkJSONResponse(resp *http.Response) error {
+	if contentType := resp.Header.Get("Content-Type"); contentType != "" {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil || (mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json")) {
+			snippet, _ := io.ReadAll(io.LimitReader(resp.Body, maxBodySnippet))
+			return fmt.Errorf("%s returned %s instead of JSON (a proxy or login page in the way?): %q", resp.Request.URL.Redacted(), contentType, strings.TrimSpace(string(snippet)))
 		}
-		weeks = append(weeks, currentWeek)
 	}
+	return nil
+}
 
-	return weeks, crossData, nil
+// redactToken describes whether a token is set without revealing it.
+func redactToken(token string) string {
+	if token == "" {
+		return "none"
+	}
+	return "present (redacted)"
 }
 
-// =============================================================================
-// Post-Processing: Update Colors for the Map
-// =============================================================================
+// gitHubGraphQLErrors turns the errors array of a GraphQL response into a
+// single descriptive error, calling out an unknown user and a token without
+// the needed scopes or permissions explicitly.
+func gitHubGraphQLErrors(username string, gqlErrors []GitHubGraphQLError) error {
+	messages := make([]string, 0, len(gqlErrors))
+	for _, e := range gqlErrors {
+		if e.Type == "NOT_FOUND" {
+			return withCode(errCodeNotFound, fmt.Errorf("GitHub user %q was not found", username))
+		}
+		if hint := gitHubTokenHint(e); hint != "" {
+			return withCode(errCodeAuth, fmt.Errorf("GitHub API error: %s", hint))
+		}
+		messages = append(messages, e.Message)
+	}
+	code := errCodeOther
+	if gqlErrors[0].Type == "FORBIDDEN" || gqlErrors[0].Type == "INSUFFICIENT_SCOPES" {
+		code = errCodeAuth
+	}
+	return withCode(code, fmt.Errorf("GitHub API error: %s", strings.Join(messages, "; ")))
+}
 
-// updateWeeksColors computes the maximum daily count and then updates every day's Color.
-func updateWeeksColors(weeks Weeks, lightMode bool) {
-	maxCount := 0
-	for _, week := range weeks {
-		for _, day := range week {
-			if day.Count > maxCount {
-				maxCount = day.Count
+// gitHubScopeLists picks the required and granted scopes out of GitHub's
+// INSUFFICIENT_SCOPES message, e.g. "The 'email' field requires one of the
+// following scopes: ['user:email', 'read:user'], but your token has only been
+// granted the: ['repo'] scopes."
+var gitHubScopeLists = regexp.MustCompile(`requires one of the following scopes: \[([^\]]*)\](?:.*granted the: \[([^\]]*)\])?`)
+
+// gitHubTokenHint explains a GraphQL error caused by the token's scopes or
+// permissions, naming what to grant; it returns "" for other errors.
+func gitHubTokenHint(e GitHubGraphQLError) string {
+	switch {
+	case e.Type == "INSUFFICIENT_SCOPES":
+		// Classic tokens report the scopes the query needs.
+		if m := gitHubScopeLists.FindStringSubmatch(e.Message); m != nil {
+			hint := fmt.Sprintf("the token lacks the scopes this query needs; grant one of %s at https://github.com/settings/tokens", scopeList(m[1]))
+			if granted := scopeList(m[2]); granted != "" {
+				hint += fmt.Sprintf(" (it has %s)", granted)
 			}
+			return hint
 		}
+		return "the token lacks the scopes this query needs; grant read:user at https://github.com/settings/tokens (" + e.Message + ")"
+	case strings.Contains(e.Message, "Resource not accessible by personal access token"):
+		// Fine-grained tokens only say that access was refused.
+		return `the fine-grained token may not read this user's profile; give it read-only access to the "Profile" account permission at https://github.com/settings/personal-access-tokens, or use a classic token with the read:user scope`
+	case strings.Contains(e.Message, "Resource not accessible by integration"):
+		return "the GitHub App token may not read this user's profile; grant the app read access to profiles, or use a personal access token with the read:user scope"
 	}
-	for i, week := range weeks {
-		for j, day := range week {
-			weeks[i][j].Color = getColor(day.Count, maxCount, lightMode)
+	return ""
+}
+
+// scopeList formats a quoted scope list such as "'user:email', 'read:user'"
+// as "user:email, read:user".
+func scopeList(quoted string) string {
+	var scopes []string
+	for _, scope := range strings.Split(quoted, ",") {
+		if scope = strings.Trim(strings.TrimSpace(scope), `'"`); scope != "" {
+			scopes = append(scopes, scope)
 		}
 	}
+	return strings.Join(scopes, ", ")
 }
 
-// =============================================================================
-// SVG Generation Functions
-// =============================================================================
+// fetchGiteaContributions queries Gitea’s events API for the given user,
+// aggregates daily totals (for the map) and also computes a breakdown (for the cross diagram).
+// The events feed is paginated, so pages are requested until an empty page is
+// returned or the events start to predate the trailing-year window. The token
+// is optional and only needed for private instances or private activity.
+// Event timestamps and the grid boundaries are both taken in loc, so an event
+// is counted on the day it happened in that zone.
+// The eventsPath selects the feed: giteaEventsPath or forgejoEventsPath.
+// Canceling ctx aborts the in-flight page request and returns the context's error.
+func fetchGiteaContributions(ctx context.Context, username, baseURL, eventsPath, token string, loc *time.Location, lightMode bool) (Weeks, CrossData, error) {
+	today, windowStart := trailingYearWindow(loc)
 
-// generateSVG produces the contribution map as an SVG file.
-// The map obeys the light/dark mode selection.
-func generateSVG(weeks Weeks, outputFilename string, lightMode bool) error {
-	numWeeks := len(weeks)
-	gridWidth := numWeeks*(cellSize+cellMargin) + cellMargin
-	gridHeight := 7*(cellSize+cellMargin) + cellMargin
-	svgWidth := gridWidth
-	svgHeight := topMargin + gridHeight
+	contributionsMap := make(map[string]int)
+	var crossData CrossData
+	unknownTypes := make(map[string]bool) // logged once each
+	reachedWindowStart := false
+	var oldest time.Time
+
+	// Classify events by giteaEventCategories as they are decoded, so only
+	// the per-day counts are kept however much activity the feed holds.
+	countEvent := func(event GiteaEvent) {
+		eventType := event.kind()
+		t, err := time.Parse(time.RFC3339, event.createdAt())
+		if err != nil {
+			return
+		}
+		t = t.In(loc)
+		if t.Before(windowStart) {
+			reachedWindowStart = true
+			return
+		}
+		if oldest.IsZero() || t.Before(oldest) {
+			oldest = t
+		}
+		dateStr := t.Format("2006-01-02")
+		contributionsMap[dateStr]++
 
-	var svg bytes.Buffer
-	svg.WriteString(fmt.Sprintf(`<svg width="%d" height="%d" xmlns="http://www.w3.org/2000/svg">`, svgWidth, svgHeight))
-	svg.WriteString("\n")
-	if lightMode {
-		svg.WriteString(fmt.Sprintf(`<rect width="%d" height="%d" fill="%s"/>`, svgWidth, svgHeight, bgLight))
-	} else {
-		svg.WriteString(fmt.Sprintf(`<rect width="%d" height="%d" fill="%s"/>`, svgWidth, svgHeight, bgDark))
+		category, known := giteaEventCategories[eventType]
+		if !known && !unknownTypes[eventType] {
+			unknownTypes[eventType] = true
+			verboseLog.Printf("Unknown %s event type %q counts toward daily totals only", baseURL, eventType)
+		}
+		crossData.addEvent(category)
 	}
-	svg.WriteString("\n")
 
-	// Determine month labels (three-letter abbreviation when a month begins).
-	var monthLabels []MonthLabel
-	for weekIndex, week := range weeks {
+	for page := 1; ; page++ {
+		events, totalCount, err := fetchGiteaEventsPage(ctx, username, baseURL, eventsPath, token, page, countEvent)
+		if err != nil {
+			return nil, CrossData{}, err
+		}
+		if events == 0 {
+			break
+		}
+		progress.pageFetched()
+		if page == 1 && totalCount >= 0 {
+			verboseLog.Printf("%s reports %d events for %s (%d pages)", baseURL, totalCount, username, (totalCount+giteaPageLimit-1)/giteaPageLimit)
+		}
+
+		// Events are returned newest first, so once one predates the window
+		// there is nothing further back worth requesting.
+		if reachedWindowStart {
+			break
+		}
+		// With X-Total-Count the last page is known, which saves requesting
+		// an empty one.
+		if totalCount >= 0 && page*giteaPageLimit >= totalCount {
+			break
+		}
+	}
+
+	// Running out of events inside the window means either the user was not
+	// active before, or the instance does not keep history that far back.
+	if !reachedWindowStart && !oldest.IsZero() {
+		days := int(today.Sub(oldest).Hours()/24) + 1
+		verboseLog.Printf("%s returned only %d days of events for %s; map may be incomplete", baseURL, days, username)
+	}
+
+	weeks := buildWeeks(contributionsMap, windowStart, today)
+	verboseLog.Printf("Built %d weeks for Gitea user %s", len(weeks), username)
+
+	return weeks, crossData, nil
+}
+
+// Weeks in a grid built from an events feed, like GitHub's calendar: the
+// current, partial week plus the 52 before it.
+const trailingWeeks = 53
+
+// trailingYearWindow returns now in loc and the midnight starting the window
+// of trailingWeeks Sunday-to-Saturday weeks whose last column holds today.
+// Counting back from today's week, rather than from a date a year ago, keeps
+// the grid the same width whatever weekday today is.
+func trailingYearWindow(loc *time.Location) (today, windowStart time.Time) {
+	return trailingYearWindowAt(time.Now().In(loc))
+}
+
+// trailingYearWindowAt is trailingYearWindow for a given current time.
+func trailingYearWindowAt(now time.Time) (today, windowStart time.Time) {
+	startDate := now.AddDate(0, 0, -int(now.Weekday())-7*(trailingWeeks-1))
+	windowStart = time.Date(startDate.Year(), startDate.Month(), startDate.Day(), 0, 0, 0, 0, now.Location())
+	return now, windowStart
+}
+
+// buildWeeks lays out daily counts keyed by YYYY-MM-DD as a grid of Sunday to
+// Saturday weeks from startDate through today, padding the last week with
+// undated days. Colors are left empty for updateWeeksColors. The counts map is
+// only looked up by date, so the grid does not depend on map order.
+func buildWeeks(counts map[string]int, startDate, today time.Time) Weeks {
+	var weeks Weeks
+	var currentWeek []ContributionDay
+	currentDate := startDate
+	for !currentDate.After(today) {
+		dateStr := currentDate.Format("2006-01-02")
+		count := counts[dateStr]
+		currentWeek = append(currentWeek, ContributionDay{
+			Date:  dateStr,
+			Count: count,
+			Color: "",
+		})
+		if currentDate.Weekday() == time.Saturday {
+			weeks = append(weeks, currentWeek)
+			currentWeek = []ContributionDay{}
+		}
+		currentDate = currentDate.AddDate(0, 0, 1)
+	}
+	if len(currentWeek) > 0 {
+		for len(currentWeek) < 7 {
+			currentWeek = append(currentWeek, ContributionDay{
+				Date:  "",
+				Count: 0,
+				Color: "",
+			})
+		}
+		weeks = append(weeks, currentWeek)
+	}
+	return weeks
+}
+
+// trimEmptyWeeks returns weeks without the leading and trailing weeks that
+// have no contributions, sharing the remaining weeks with the original. A grid
+// without any contributions trims to none.
+func trimEmptyWeeks(weeks Weeks) Weeks {
+	empty := func(week []ContributionDay) bool {
 		for _, day := range week {
-			if day.Date != "" {
-				t, err := time.Parse("2006-01-02", day.Date)
-				if err != nil {
-					continue
-				}
-				if t.Day() == 1 {
-					label := t.Format("Jan")
-					if len(monthLabels) == 0 || monthLabels[len(monthLabels)-1].Label != label {
-						x := cellMargin + weekIndex*(cellSize+cellMargin)
-						monthLabels = append(monthLabels, MonthLabel{X: x, Label: label})
-					}
-					break
-				}
+			if day.Count > 0 {
+				return false
 			}
 		}
+		return true
 	}
-
-	// Text color follows the mode.
-	textFill := "black"
-	if !lightMode {
-		textFill = "white"
+	start, end := 0, len(weeks)
+	for start < end && empty(weeks[start]) {
+		start++
 	}
-	for _, ml := range monthLabels {
-		svg.WriteString(fmt.Sprintf(`<text x="%d" y="%d" fill="%s" font-family="sans-serif" font-size="10px">%s</text>`, ml.X, topMargin-4, textFill, ml.Label))
-		svg.WriteString("\n")
+	for end > start && empty(weeks[end-1]) {
+		end--
 	}
+	return weeks[start:end]
+}
 
-	// Draw each cell.
-	for weekIndex, week := range weeks {
-		for dayIndex, day := range week {
-			x := cellMargin + weekIndex*(cellSize+cellMargin)
-			y := topMargin + cellMargin + dayIndex*(cellSize+cellMargin)
-			strokeAttr := ""
-			if !lightMode {
-				strokeAttr = ` stroke="#333333" stroke-width="1"`
-			}
-			tooltip := ""
-			if day.Date != "" {
-				tooltip = fmt.Sprintf("%s: %d contributions", day.Date, day.Count)
+// startWeeksOn returns weeks laid out in columns that begin on start, with
+// undated padding before the first day and after the last. Every platform
+// yields Sunday-first weeks, so a Sunday start returns them unchanged.
+func startWeeksOn(weeks Weeks, start time.Weekday) Weeks {
+	var days []ContributionDay
+	aligned := true
+	for i, week := range weeks {
+		if i < len(weeks)-1 && len(week) != 7 {
+			aligned = false
+		}
+		for j, day := range week {
+			if day.Date == "" {
+				continue
+			}
+			t, err := time.Parse("2006-01-02", day.Date)
+			if err != nil {
+				continue
+			}
+			if int(t.Weekday()) != (int(start)+j)%7 {
+				aligned = false
 			}
-			rect := fmt.Sprintf(`<rect x="%d" y="%d" width="%d" height="%d" fill="%s"%s>
-  <title>%s</title>
-</rect>`, x, y, cellSize, cellSize, day.Color, strokeAttr, tooltip)
-			svg.WriteString(rect)
-			svg.WriteString("\n")
+			days = append(days, day)
 		}
 	}
+	if aligned || len(days) == 0 {
+		return weeks
+	}
 
-	svg.WriteString("</svg>")
-	return ioutil.WriteFile(outputFilename, svg.Bytes(), 0644)
+	first, _ := time.Parse("2006-01-02", days[0].Date)
+	var regrouped Weeks
+	week := make([]ContributionDay, (int(first.Weekday())-int(start)+7)%7)
+	for _, day := range days {
+		week = append(week, day)
+		if len(week) == 7 {
+			regrouped = append(regrouped, week)
+			week = nil
+		}
+	}
+	if len(week) > 0 {
+		regrouped = append(regrouped, append(week, make([]ContributionDay, 7-len(week))...))
+	}
+	return regrouped
 }
 
-// generateCrossSVG produces an SVG “cross” diagram showing the breakdown of four contribution types.
-// The layout is as follows:
-//   - Top: Code Reviews
-//   - Bottom: Pull Requests
-//   - Left: Commits
-//   - Right: Issues
-//
-// In addition to printing the label and percentage at each arm, this function computes a weighted (x, y)
-// point and draws a large circle (dot) at that point. This function now obeys the lightMode flag:
-// if lightMode is true, the cross diagram uses a white background, and the dot and text are chosen
-// from the light color scheme; otherwise, it uses a black background with the dark scheme.
-func generateCrossSVG(crossData CrossData, outputFilename string, lightMode bool) error {
-	total := crossData.Commits + crossData.PullRequests + crossData.Issues + crossData.CodeReviews
-	var commitsPerc, prPerc, issuesPerc, codeReviewsPerc float64
-	if total > 0 {
-		commitsPerc = float64(crossData.Commits) / float64(total) * 100
-		prPerc = float64(crossData.PullRequests) / float64(total) * 100
-		issuesPerc = float64(crossData.Issues) / float64(total) * 100
-		codeReviewsPerc = float64(crossData.CodeReviews) / float64(total) * 100
-	}
-
-	// Choose colors based on the lightMode flag.
-	var bg, dot, text string
-	if lightMode {
-		bg = bgLight
-		dot = lightBucketColors[4]  // brightest green from light scheme
-		text = lightBucketColors[2] // mid-level green from light scheme
-	} else {
-		bg = bgDark
-		dot = darkBucketColors[4]  // brightest green from dark scheme
-		text = darkBucketColors[2] // mid-level green from dark scheme
+// fetchGiteaEventsPage fetches a single page of the Gitea events feed for username.
+// When a token is given it is sent using Gitea's "Authorization: token" scheme.
+// The page is decoded one event at a time, each handed to visit and then
+// dropped, so the page is never held in memory as a whole. It returns the
+// number of events on the page and the feed's X-Total-Count header, or -1
+// when it is missing.
+func fetchGiteaEventsPage(ctx context.Context, username, baseURL, eventsPath, token string, page int, visit func(GiteaEvent)) (int, int, error) {
+	pageURL := baseURL + fmt.Sprintf(eventsPath, username)
+	separator := "?"
+	if strings.Contains(pageURL, "?") {
+		separator = "&"
+	}
+	pageURL += fmt.Sprintf("%spage=%d&limit=%d", separator, page, giteaPageLimit)
+	req, err := http.NewRequestWithContext(ctx, "GET", pageURL, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "token "+token)
 	}
+	verboseLog.Printf("GET %s (token: %s)", pageURL, redactToken(token))
 
-	var svg bytes.Buffer
-	svg.WriteString(fmt.Sprintf(`<svg width="%d" height="%d" xmlns="http://www.w3.org/2000/svg">`, crossSVGWidth, crossSVGHeight))
-	svg.WriteString("\n")
-	// Background
-	svg.WriteString(fmt.Sprintf(`<rect width="%d" height="%d" fill="%s"/>`, crossSVGWidth, crossSVGHeight, bg))
-	svg.WriteString("\n")
-	// Draw dashed cross lines using the dot color.
-	svg.WriteString(fmt.Sprintf(`<line x1="%d" y1="0" x2="%d" y2="%d" stroke="%s" stroke-dasharray="4"/>`, crossCenterX, crossCenterX, crossSVGHeight, dot))
-	svg.WriteString("\n")
-	svg.WriteString(fmt.Sprintf(`<line x1="0" y1="%d" x2="%d" y2="%d" stroke="%s" stroke-dasharray="4"/>`, crossCenterY, crossSVGWidth, crossCenterY, dot))
-	svg.WriteString("\n")
-	// Top: Code Reviews
-	svg.WriteString(fmt.Sprintf(`<text x="%d" y="%d" text-anchor="middle" font-family="sans-serif" font-size="14px" fill="%s">Code Reviews</text>`, crossCenterX, topY, text))
-	svg.WriteString("\n")
-	svg.WriteString(fmt.Sprintf(`<text x="%d" y="%d" text-anchor="middle" font-family="sans-serif" font-size="12px" fill="%s">%0.1f%%</text>`, crossCenterX, topY+18, text, codeReviewsPerc))
-	svg.WriteString("\n")
-	// Bottom: Pull Requests
-	svg.WriteString(fmt.Sprintf(`<text x="%d" y="%d" text-anchor="middle" font-family="sans-serif" font-size="14px" fill="%s">Pull Requests</text>`, crossCenterX, bottomY, text))
-	svg.WriteString("\n")
-	svg.WriteString(fmt.Sprintf(`<text x="%d" y="%d" text-anchor="middle" font-family="sans-serif" font-size="12px" fill="%s">%0.1f%%</text>`, crossCenterX, bottomY+18, text, prPerc))
-	svg.WriteString("\n")
-	// Left: Commits
-	svg.WriteString(fmt.Sprintf(`<text x="%d" y="%d" text-anchor="middle" font-family="sans-serif" font-size="14px" fill="%s">Commits</text>`, leftX, crossCenterY, text))
-	svg.WriteString("\n")
-	svg.WriteString(fmt.Sprintf(`<text x="%d" y="%d" text-anchor="middle" font-family="sans-serif" font-size="12px" fill="%s">%0.1f%%</text>`, leftX, crossCenterY+18, text, commitsPerc))
-	svg.WriteString("\n")
-	// Right: Issues
-	svg.WriteString(fmt.Sprintf(`<text x="%d" y="%d" text-anchor="middle" font-family="sans-serif" font-size="14px" fill="%s">Issues</text>`, rightX, crossCenterY, text))
-	svg.WriteString("\n")
-	svg.WriteString(fmt.Sprintf(`<text x="%d" y="%d" text-anchor="middle" font-family="sans-serif" font-size="12px" fill="%s">%0.1f%%</text>`, rightX, crossCenterY+18, text, issuesPerc))
-	svg.WriteString("\n")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return 0, 0, ctx.Err()
+		}
+		return 0, 0, err
+	}
+	defer resp.Body.Close()
+	verboseLog.Printf("Gitea responded %s", resp.Status)
 
-	// Compute the weighted (x, y) point.
-	var x, y float64
-	if (crossData.Commits + crossData.Issues) > 0 {
-		// x coordinate: interpolate from left (commits) to right (issues)
-		x = float64(leftX) + (float64(crossData.Issues)/float64(crossData.Commits+crossData.Issues))*float64(rightX-leftX)
-	} else {
-		x = float64(crossCenterX)
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return 0, 0, withCode(statusCode(resp.StatusCode), fmt.Errorf("Gitea API error: %s", string(bodyBytes)))
 	}
-	if (crossData.CodeReviews + crossData.PullRequests) > 0 {
-		// y coordinate: interpolate from top (code reviews) to bottom (pull requests)
-		y = float64(topY) + (float64(crossData.PullRequests)/float64(crossData.CodeReviews+crossData.PullRequests))*float64(bottomY-topY)
-	} else {
-		y = float64(crossCenterY)
+
+	totalCount := -1
+	if n, err := strconv.Atoi(resp.Header.Get("X-Total-Count")); err == nil && n >= 0 {
+		totalCount = n
 	}
-	// Draw a big circle (dot) at the computed point.
-	svg.WriteString(fmt.Sprintf(`<circle cx="%0.1f" cy="%0.1f" r="10" fill="%s"/>`, x, y, dot))
-	svg.WriteString("\n")
 
-	svg.WriteString("</svg>")
-	return ioutil.WriteFile(outputFilename, svg.Bytes(), 0644)
+	if err := checkJSONResponse(resp); err != nil {
+		return 0, 0, err
+	}
+	// A Gitea feed is a JSON array; null stands for an empty one.
+	dec := json.NewDecoder(resp.Body)
+	tok, err := dec.Token()
+	if err != nil {
+		return 0, 0, err
+	}
+	if tok == nil {
+		return 0, totalCount, nil
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		return 0, 0, fmt.Errorf("Gitea API returned %v instead of an array of events", tok)
+	}
+	events := 0
+	for dec.More() {
+		var event GiteaEvent
+		if err := dec.Decode(&event); err != nil {
+			return 0, 0, err
+		}
+		visit(event)
+		events++
+	}
+	if _, err := dec.Token(); err != nil {
+		return 0, 0, err
+	}
+	return events, totalCount, nil
 }
 
 // =============================================================================
-// Main (using mow.cli)
+// Post-Processing: Update Colors for the Map
 // =============================================================================
 
-func main() {
-	app := cli.App("contribmap", "Generate a contribution map (heatmap) and a cross diagram showing contribution breakdowns for GitHub or Gitea users.")
+// ColorScale selects how daily counts map onto colors.
+type ColorScale struct {
+	Kind     string // scaleLinear, scaleQuantile or scaleContinuous
+	MaxCount int    // the count that gets the brightest color; 0 uses the busiest day
+	HalfLife int    // with --recency-decay, days over which a day's weight halves; 0 weighs all days alike
+	Floor    int    // with --count-threshold, counts below this are colored as no contributions
+}
 
-	platform := app.String(cli.StringOpt{
-		Name:  "platform",
-		Value: "github",
-		Desc:  "Platform to use: github or gitea",
-	})
-	user := app.String(cli.StringOpt{
-		Name: "user",
-		Desc: "Username on the chosen platform",
-	})
-	token := app.String(cli.StringOpt{
-		Name: "token",
-		Desc: "GitHub token (required for GitHub; not needed for Gitea)",
-	})
-	giteaURL := app.String(cli.StringOpt{
-		Name:  "gitea-url",
-		Value: "https://try.gitea.io",
-		Desc:  "Base URL for Gitea instance (used if platform is gitea)",
-	})
-	lightMode := app.Bool(cli.BoolOpt{
-		Name:  "light-mode",
-		Value: false,
-		Desc:  "Use the light color scheme for both the map and cross diagram (default is dark mode)",
-	})
-	outputFormat := app.String(cli.StringOpt{
-		Name:  "output",
-		Value: "svg",
-		Desc:  "Output format (default 'svg')",
-	})
+// maxCount returns the count the scale tops out at for weeks.
+func (s ColorScale) maxCount(weeks Weeks) int {
+	if s.MaxCount > 0 {
+		return s.MaxCount
+	}
+	return maxDailyCount(weeks)
+}
 
-	app.Action = func() {
-		if *user == "" {
-			fmt.Println("Please provide a username using the --user option.")
-			os.Exit(1)
+// updateWeeksColors computes the maximum daily count and then updates every day's Color.
+// The scale kind selects how counts map onto buckets: scaleLinear splits
+// 1..maxCount evenly, scaleQuantile uses percentiles of the nonzero counts and
+// scaleContinuous draws every nonzero day in the brightest bucket with an
+// opacity proportional to count/maxCount. Counts above a pinned MaxCount get
+// the brightest color. With a HalfLife or a Floor, see updateAdjustedColors.
+func updateWeeksColors(weeks Weeks, theme Theme, scale ColorScale) {
+	if scale.HalfLife > 0 || scale.Floor > 0 {
+		updateAdjustedColors(weeks, theme, scale)
+		return
+	}
+	if scale.Kind == scaleContinuous {
+		maxCount := scale.maxCount(weeks)
+		for i, week := range weeks {
+			for j, day := range week {
+				weeks[i][j].Color, weeks[i][j].Opacity = theme.Zero, 0
+				if day.Count > 0 {
+					weeks[i][j].Color = theme.brightestBucket()
+					weeks[i][j].Opacity = min(max(float64(day.Count)/float64(maxCount), minContinuousOpacity), 1)
+				}
+			}
 		}
-		if *outputFormat != "svg" {
-			fmt.Fprintf(os.Stderr, "Unknown output format: %s. Currently only 'svg' is supported.\n", *outputFormat)
-			os.Exit(1)
+		return
+	}
+
+	if scale.Kind == scaleQuantile {
+		thresholds := quantileThresholds(weeks, len(theme.Buckets))
+		for i, week := range weeks {
+			for j, day := range week {
+				weeks[i][j].Color = getQuantileColor(day.Count, thresholds, theme)
+			}
 		}
+		return
+	}
 
-		var weeks Weeks
-		var crossData CrossData
-		var err error
+	maxCount := scale.maxCount(weeks)
+	for i, week := range weeks {
+		for j, day := range week {
+			weeks[i][j].Color = getColor(day.Count, maxCount, theme)
+		}
+	}
+}
 
-		if strings.ToLower(*platform) == "github" {
-			if *token == "" {
-				fmt.Println("A GitHub token is required when using the GitHub platform. Provide it using the --token option.")
-				os.Exit(1)
+// decayPrecision scales decayed counts so that their fractions survive being
+// bucketed as integers.
+const decayPrecision = 1000
+
+// updateAdjustedColors colors weeks as updateWeeksColors does, but from
+// adjusted counts. Counts below scale.Floor are taken as zero, so that trivial
+// days such as a single automated commit stay blank. With a HalfLife the rest
+// are weighted by recency: a day's count is halved for every scale.HalfLife
+// days it lies before the last dated day in weeks, so that recent activity
+// stands out; a pinned MaxCount applies to the weighted counts, and any day
+// left with contributions keeps at least the faintest color. The counts
+// themselves, shown in the tooltips, are left as they are.
+func updateAdjustedColors(weeks Weeks, theme Theme, scale ColorScale) {
+	var last time.Time
+	for _, week := range weeks {
+		for _, day := range week {
+			if t, err := time.Parse("2006-01-02", day.Date); err == nil && t.After(last) {
+				last = t
 			}
-			fmt.Printf("Fetching contributions for GitHub user %s...\n", *user)
-			weeks, crossData, err = fetchGitHubContributions(*user, *token, *lightMode)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error fetching GitHub contributions: %v\n", err)
-				os.Exit(1)
+		}
+	}
+
+	adjusted := make(Weeks, len(weeks))
+	for i, week := range weeks {
+		adjusted[i] = make([]ContributionDay, len(week))
+		for j, day := range week {
+			adjusted[i][j] = day
+			if day.Count < scale.Floor {
+				adjusted[i][j].Count = 0
+				continue
 			}
-		} else if strings.ToLower(*platform) == "gitea" {
-			fmt.Printf("Fetching contributions for Gitea user %s from %s...\n", *user, *giteaURL)
-			weeks, crossData, err = fetchGiteaContributions(*user, *giteaURL, *lightMode)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error fetching Gitea contributions: %v\n", err)
-				os.Exit(1)
+			t, err := time.Parse("2006-01-02", day.Date)
+			if scale.HalfLife == 0 || err != nil || day.Count <= 0 {
+				continue
+			}
+			weight := math.Pow(0.5, last.Sub(t).Hours()/24/float64(scale.HalfLife))
+			adjusted[i][j].Count = max(int(math.Round(float64(day.Count)*weight*decayPrecision)), 1)
+		}
+	}
+	if scale.HalfLife > 0 {
+		scale.MaxCount *= decayPrecision
+	}
+	scale.HalfLife, scale.Floor = 0, 0
+	updateWeeksColors(adjusted, theme, scale)
+
+	for i, week := range adjusted {
+		for j, day := range week {
+			weeks[i][j].Color, weeks[i][j].Opacity = day.Color, day.Opacity
+		}
+	}
+}
+
+// maxDailyCount returns the highest single-day count in weeks.
+func maxDailyCount(weeks Weeks) int {
+	maxCount := 0
+	for _, week := range weeks {
+		for _, day := range week {
+			if day.Count > maxCount {
+				maxCount = day.Count
 			}
-		} else {
-			fmt.Fprintf(os.Stderr, "Unknown platform: %s. Use 'github' or 'gitea'.\n", *platform)
-			os.Exit(1)
 		}
+	}
+	return maxCount
+}
+
+// updateUniformColors colors several grids on one shared scale, so the same
+// count gets the same color in every grid. The week slices are shared with the
+// originals, so coloring the combined grid updates each of them in place.
+func updateUniformColors(grids []LabeledWeeks, theme Theme, scale ColorScale) {
+	var combined Weeks
+	for _, grid := range grids {
+		combined = append(combined, grid.Weeks...)
+	}
+	updateWeeksColors(combined, theme, scale)
+}
+
+// =============================================================================
+// SVG Generation Functions
+// =============================================================================
+
+// Every SVG generator is deterministic: the same grids, totals and options
+// always produce the same bytes, so output can be compared against golden
+// files. Elements are written in slice order only; Go maps are used for
+// lookups but never ranged over while writing, and numbers are formatted with
+// fixed verbs. The one exception is the month calendar of a grid without a
+// single dated day, which falls back to the current month (see
+// calendarMonthStarts). Keep it that way: anything collected in a map must be
+// sorted before it is written.
+
+// generateSVG produces the contribution map of grid as an SVG file; its label
+// is not drawn. The map obeys the light/dark mode selection, the theme and the
+// cell geometry in opts.
+func generateSVG(grid LabeledWeeks, outputFilename string, opts MapOptions) error {
+	data, err := renderSVG(grid, opts)
+	if err != nil {
+		return err
+	}
+	return writeSVGFile(outputFilename, data, opts.Gzip)
+}
+
+// renderSVG returns the contribution map SVG written by generateSVG, drawn
+// with opts.Template or else the built-in map template.
+func renderSVG(grid LabeledWeeks, opts MapOptions) ([]byte, error) {
+	tmpl := opts.Template
+	if tmpl == nil {
+		tmpl = builtinMapTemplate
+	}
+	return renderTemplateSVG(tmpl, grid, opts)
+}
+
+// generateMultiSVG produces a single SVG with one labeled contribution map per
+// entry in grids, stacked vertically in the given order. Each grid keeps the
+// colors already assigned to its days, so callers decide whether the scale is
+// shared (see updateUniformColors) or computed per grid.
+func generateMultiSVG(grids []LabeledWeeks, outputFilename string, opts MapOptions) error {
+	data, err := renderMultiSVG(grids, opts)
+	if err != nil {
+		return err
+	}
+	return writeSVGFile(outputFilename, data, opts.Gzip)
+}
+
+// renderMultiSVG returns the stacked maps SVG written by generateMultiSVG.
+func renderMultiSVG(grids []LabeledWeeks, opts MapOptions) ([]byte, error) {
+	svgWidth, svgHeight, err := multiMapSize(grids, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var svg bytes.Buffer
+	writeSVGHeader(&svg, svgWidth, svgHeight, "Contribution maps", multiMapSummary(grids))
+	if err := writeAutoModeStyle(&svg, opts); err != nil {
+		return nil, err
+	}
+	opts.Theme.writeBackgroundAttrs(&svg, svgWidth, svgHeight, opts.autoClass("bg"))
+	if err := writeMultiMapGrid(&svg, grids, opts); err != nil {
+		return nil, err
+	}
+	svg.WriteString("</svg>")
+	return finishSVG(svg.Bytes(), opts.Minify, opts.StripTooltips), nil
+}
 
-		updateWeeksColors(weeks, *lightMode)
-		mapFilename := "contributions.svg"
-		if err := generateSVG(weeks, mapFilename, *lightMode); err != nil {
-			fmt.Fprintf(os.Stderr, "Error generating contribution map: %v\n", err)
-			os.Exit(1)
+// multiMapSize returns the width and height of the stacked, labeled maps drawn
+// by writeMultiMapGrid.
+func multiMapSize(grids []LabeledWeeks, opts MapOptions) (int, int, error) {
+	if err := opts.Layout.validate(); err != nil {
+		return 0, 0, err
+	}
+	headerHeight := opts.Layout.topMargin()
+	svgWidth, svgHeight := 0, 0
+	for _, grid := range grids {
+		width, height, err := mapGridSize(len(grid.Weeks), opts)
+		if err != nil {
+			return 0, 0, err
 		}
-		fmt.Printf("Contribution map generated and saved to %s\n", mapFilename)
+		if width > svgWidth {
+			svgWidth = width
+		}
+		svgHeight += headerHeight + height
+	}
+	if svgHeight > maxSVGDimension {
+		return 0, 0, fmt.Errorf("combined contribution map would exceed %d pixels tall; render fewer users", maxSVGDimension)
+	}
+	return svgWidth, svgHeight, nil
+}
 
-		crossFilename := "contributions_cross.svg"
-		if err := generateCrossSVG(crossData, crossFilename, *lightMode); err != nil {
-			fmt.Fprintf(os.Stderr, "Error generating cross diagram: %v\n", err)
-			os.Exit(1)
+// multiMapSummary describes every grid for an accessible label.
+func multiMapSummary(grids []LabeledWeeks) string {
+	summaries := make([]string, len(grids))
+	for i, grid := range grids {
+		summaries[i] = grid.Label + ": " + mapSummary(grid.Weeks)
+	}
+	return strings.Join(summaries, "; ")
+}
+
+// writeMultiMapGrid writes each grid below a label naming it, stacked vertically.
+func writeMultiMapGrid(svg *bytes.Buffer, grids []LabeledWeeks, opts MapOptions) error {
+	if err := writeZeroCellDef(svg, opts); err != nil {
+		return err
+	}
+	headerHeight := opts.Layout.topMargin()
+	// Text sits directly on the theme background.
+	textFill := contrastColor(opts.Theme.Background)
+
+	offsetY := 0
+	for _, grid := range grids {
+		_, height, _ := mapGridSize(len(grid.Weeks), opts)
+		// User label above the grid's month labels.
+		svg.WriteString(fmt.Sprintf(`<text x="%d" y="%d" fill="%s"%s font-family="sans-serif" font-size="%dpx" font-weight="bold">%s</text>`, opts.Layout.CellMargin, offsetY+headerHeight-4, textFill, opts.autoClass("fg"), opts.Layout.labelFontSize()+2, escapeXML(grid.Label)))
+		svg.WriteString("\n")
+		offsetY += headerHeight
+		svg.WriteString(fmt.Sprintf(`<g transform="translate(0,%d)">`, offsetY))
+		svg.WriteString("\n")
+		if err := writeMapGrid(svg, grid, opts); err != nil {
+			return err
 		}
-		fmt.Printf("Cross diagram generated and saved to %s\n", crossFilename)
+		svg.WriteString("</g>\n")
+		offsetY += height
+	}
+	return nil
+}
+
+// mapGridSize returns the width and height of a contribution map with numWeeks
+// columns, including the label margins and the streak legend when enabled.
+func mapGridSize(numWeeks int, opts MapOptions) (int, int, error) {
+	layout := opts.Layout.forWeeks(numWeeks)
+	if err := layout.validate(); err != nil {
+		return 0, 0, err
+	}
+	cellSize := layout.CellSize
+	cellMargin := layout.CellMargin
+	if numWeeks == 0 {
+		// An empty grid is drawn as a year-wide placeholder with a message.
+		numWeeks = placeholderWeeks
+	}
+	columns := layout.gridColumns(numWeeks)
+	if columns > (maxSVGDimension-cellMargin-layout.leftMargin())/(cellSize+cellMargin) {
+		return 0, 0, fmt.Errorf("contribution map would exceed %d pixels wide; reduce --cell-size or --cell-margin", maxSVGDimension)
+	}
+	if bands := layout.gridBands(numWeeks); bands > (maxSVGDimension-layout.titleHeight()-opts.legendHeight())/layout.bandHeight() {
+		return 0, 0, fmt.Errorf("contribution map would exceed %d pixels tall; reduce --wrap, --cell-size or --cell-margin", maxSVGDimension)
 	}
+	gridWidth := columns*(cellSize+cellMargin) + cellMargin
+	return layout.leftMargin() + gridWidth, layout.titleHeight() + layout.gridBands(numWeeks)*layout.bandHeight() + opts.legendHeight(), nil
+}
+
+// legendHeight returns the vertical space below the grid for the streak legend.
+func (o MapOptions) legendHeight() int {
+	if !o.HighlightStreak {
+		return 0
+	}
+	return o.Layout.topMargin()
+}
 
-	app.Run(os.Args)
+// writeMapGrid writes the contribution map of grid to svg, positioned relative
+// to the current origin, with the "grid" template of the built-in map.
+func writeMapGrid(svg *bytes.Buffer, grid LabeledWeeks, opts MapOptions) error {
+	data, err := newMapTemplateData(grid, opts)
+	if err != nil {
+		return err
+	}
+	return builtinMapTemplate.ExecuteTemplate(svg, "grid", data)
+}
+
+// writeZeroCellDef defines the cell that writeMapGrid places with <use> for
+// every day without contributions. It is written once per document, before
+// any map.
+func writeZeroCellDef(svg *bytes.Buffer, opts MapOptions) error {
+	return builtinMapTemplate.ExecuteTemplate(svg, "zero-cell", newTemplateZeroCell(opts))
+}
+
+// writeSVGHeader writes the opening <svg> tag with accessibility attributes,
+// followed by a <title> and <desc> for screen readers. The xlink namespace is
+// declared for the cells' xlink:href, which SVG 1.1 renderers require.
+func writeSVGHeader(svg *bytes.Buffer, width, height int, title, desc string) {
+	svg.WriteString(fmt.Sprintf(`<svg width="%d" height="%d" xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" role="img" aria-label="%s">`, width, height, escapeXML(desc)))
+	svg.WriteString("\n")
+	svg.WriteString(fmt.Sprintf(`<title>%s</title>`, escapeXML(title)))
+	svg.WriteString("\n")
+	svg.WriteString(fmt.Sprintf(`<desc>%s</desc>`, escapeXML(desc)))
+	svg.WriteString("\n")
+}
+
+// mapSummary describes the contributions in weeks for an accessible label.
+func mapSummary(weeks Weeks) string {
+	total, first, last := 0, "", ""
+	for _, week := range weeks {
+		for _, day := range week {
+			if day.Date == "" {
+				continue
+			}
+			if first == "" {
+				first = day.Date
+			}
+			last = day.Date
+			total += day.Count
+		}
+	}
+	if first == "" {
+		return "No contributions"
+	}
+	return fmt.Sprintf("%d contributions from %s to %s", total, first, last)
+}
+
+// totalHeading returns the --title header, like GitHub's own
+// "1,234 contributions in the last year", or for a --range window
+// "1,234 contributions from 2022-01-01 to 2023-12-31".
+func totalHeading(total int, window *dateWindow) string {
+	period := "in the last year"
+	if window != nil {
+		period = "from " + window.String()
+	}
+	if total == 1 {
+		return "1 contribution " + period
+	}
+	return formatThousands(total) + " contributions " + period
+}
+
+// formatThousands formats n with commas between groups of three digits.
+func formatThousands(n int) string {
+	digits := strconv.Itoa(n)
+	sign := ""
+	if n < 0 {
+		sign, digits = "-", digits[1:]
+	}
+	for i := len(digits) - 3; i > 0; i -= 3 {
+		digits = digits[:i] + "," + digits[i:]
+	}
+	return sign + digits
+}
+
+// monthLabels returns a three-letter label for each month that begins within
+// weeks, positioned above the week column containing its first day.
+// A month also counts as beginning in the first column holding any of its
+// days, so a label is not lost when the 1st itself is missing from the data,
+// e.g. on padding or in a gappy --input file.
+// When the layout wraps, each later row band also starts with the month its
+// first column is in, so every band can be read on its own.
+// On maps longer than a year, January is labeled with its year instead, so
+// that the repeated months can be told apart. A label that would run into the
+// one before it on the same row is left out.
+func monthLabels(weeks Weeks, layout MapLayout) []MonthLabel {
+	var labels []MonthLabel
+	multiYear := len(weeks) > trailingWeeks
+	for weekIndex, week := range weeks {
+		var first time.Time
+		monthStart := monthStartIn(weeks, weekIndex)
+		for _, day := range week {
+			if t, err := time.Parse("2006-01-02", day.Date); err == nil {
+				first = t
+				break
+			}
+		}
+		// Skip the band's own label when a month begins right after it,
+		// where the two labels would overlap.
+		bandStart := layout.weeksPerRow > 0 && weekIndex > 0 && weekIndex%layout.weeksPerRow == 0
+		if monthStart.IsZero() && bandStart && !startsMonth(weeks, weekIndex+1) && !startsMonth(weeks, weekIndex+2) {
+			monthStart = first
+		}
+		if monthStart.IsZero() {
+			continue
+		}
+		x, y := layout.cellOrigin(weekIndex, 0)
+		y -= layout.CellMargin + 4
+		label := layout.Locale.month(monthStart.Month())
+		if multiYear && monthStart.Month() == time.January {
+			label = strconv.Itoa(monthStart.Year())
+		}
+		if len(labels) > 0 {
+			last := labels[len(labels)-1]
+			if last.Y == y && (last.Label == label || x < last.X+layout.labelWidth(last.Label)) {
+				continue
+			}
+		}
+		labels = append(labels, MonthLabel{X: x, Y: y, Label: label})
+	}
+	return labels
+}
+
+// startsMonth reports whether a month begins in week column weekIndex.
+func startsMonth(weeks Weeks, weekIndex int) bool {
+	return weekIndex < len(weeks) && !monthStartIn(weeks, weekIndex).IsZero()
+}
+
+// monthStartIn returns the first day of week column weekIndex that is the 1st
+// of its month or in a later month than the last dated day before the column,
+// or the zero time when no month begins there.
+func monthStartIn(weeks Weeks, weekIndex int) time.Time {
+	previous := ""
+	for i := weekIndex - 1; i >= 0 && previous == ""; i-- {
+		for _, day := range weeks[i] {
+			if day.Date != "" {
+				previous = day.Date
+			}
+		}
+	}
+	for _, day := range weeks[weekIndex] {
+		t, err := time.Parse("2006-01-02", day.Date)
+		if err != nil {
+			continue
+		}
+		if t.Day() == 1 || (previous != "" && day.Date[:7] != previous[:7]) {
+			return t
+		}
+		previous = day.Date
+	}
+	return time.Time{}
+}
+
+// escapeXML escapes s for use as SVG text content or an attribute value.
+func escapeXML(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// writeOutputFile writes data to filename, naming the file in any error.
+func writeOutputFile(filename string, data []byte) error {
+	if filename == "-" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	if dir := filepath.Dir(filename); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("creating directory for %s: %w", filename, err)
+		}
+	}
+	if err := os.WriteFile(filename, data, 0644); err != nil {
+		var pathErr *fs.PathError
+		if errors.As(err, &pathErr) {
+			err = pathErr.Err
+		}
+		return fmt.Errorf("writing %s: %w", filename, err)
+	}
+	return nil
+}
+
+// validate checks that the layout describes a drawable grid whose height fits
+// within maxSVGDimension.
+func (l MapLayout) validate() error {
+	if l.CellSize < 1 {
+		return fmt.Errorf("cell size must be positive, got %d", l.CellSize)
+	}
+	if l.CellMargin < 0 {
+		return fmt.Errorf("cell margin must not be negative, got %d", l.CellMargin)
+	}
+	if l.Wrap < 0 || l.Wrap > placeholderWeeks {
+		return fmt.Errorf("wrap must be between 1 and %d rows, got %d", placeholderWeeks, l.Wrap)
+	}
+	// Seven rows plus the label margins stay well within ten cell pitches.
+	if l.CellSize+l.CellMargin > maxSVGDimension/10 {
+		return fmt.Errorf("cell size %d with margin %d exceeds the maximum SVG size", l.CellSize, l.CellMargin)
+	}
+	return nil
+}
+
+// cellAnimation returns the SMIL element that fades in the cells of one week
+// column, sweeping left to right over duration; it is empty when duration is
+// 0. Each column stays hidden until its turn and then fades in over the last
+// fifth of the timeline's length, and fill="freeze" keeps the final frame
+// identical to the static map.
+func cellAnimation(weekIndex, numWeeks int, duration time.Duration) string {
+	if duration <= 0 || numWeeks == 0 {
+		return ""
+	}
+	start := 0.8 * float64(weekIndex) / float64(numWeeks)
+	end := start + 0.2
+	return fmt.Sprintf("\n  "+`<animate attributeName="opacity" values="0;0;1;1" keyTimes="0;%0.4f;%0.4f;1" dur="%gs" fill="freeze"/>`, start, end, duration.Seconds())
+}
+
+// cornerRadius returns the rx/ry of a cell: about a fifth of the cell size,
+// as on GitHub, and never more than half of it. It is 0 unless Rounded is set.
+func (l MapLayout) cornerRadius() int {
+	if !l.Rounded {
+		return 0
+	}
+	return min((l.CellSize+2)/5, l.CellSize/2)
+}
+
+// labelFontSize returns the month-label font size, scaled with the cell size
+// but never below minLabelFontSize.
+func (l MapLayout) labelFontSize() int {
+	return max(baseLabelFontSize*l.CellSize/defaultCellSize, minLabelFontSize)
+}
+
+// labelWidth estimates the width of a month label; sans-serif letters and
+// digits are roughly 0.6em wide, and a cell margin keeps labels apart.
+func (l MapLayout) labelWidth(label string) int {
+	return int(0.6*float64(utf8.RuneCountInString(label)*l.labelFontSize())) + l.CellMargin
+}
+
+// cellOrigin returns the top-left corner of the cell for the given week column
+// and day row, relative to the map's origin.
+// With wrapping, week columns continue in the next row band.
+func (l MapLayout) cellOrigin(weekIndex, dayIndex int) (int, int) {
+	pitch := l.CellSize + l.CellMargin
+	band := 0
+	if l.weeksPerRow > 0 {
+		band, weekIndex = weekIndex/l.weeksPerRow, weekIndex%l.weeksPerRow
+	}
+	return l.leftMargin() + l.CellMargin + weekIndex*pitch, l.titleHeight() + band*l.bandHeight() + l.topMargin() + l.CellMargin + dayIndex*pitch
+}
+
+// forWeeks returns the layout for a map of numWeeks weeks, splitting them
+// evenly over Wrap rows when wrapping is enabled.
+func (l MapLayout) forWeeks(numWeeks int) MapLayout {
+	l.weeksPerRow = 0
+	if l.Wrap > 1 && numWeeks > 0 {
+		l.weeksPerRow = (numWeeks + l.Wrap - 1) / l.Wrap
+	}
+	return l
+}
+
+// gridColumns and gridBands return the columns and row bands a layout from
+// forWeeks uses for numWeeks weeks.
+func (l MapLayout) gridColumns(numWeeks int) int {
+	if l.weeksPerRow > 0 {
+		return l.weeksPerRow
+	}
+	return numWeeks
+}
+
+func (l MapLayout) gridBands(numWeeks int) int {
+	if l.weeksPerRow > 0 {
+		return (numWeeks + l.weeksPerRow - 1) / l.weeksPerRow
+	}
+	return 1
+}
+
+// bandHeight returns the height of one row band: its month labels and seven
+// rows of cells.
+func (l MapLayout) bandHeight() int {
+	return l.topMargin() + 7*(l.CellSize+l.CellMargin) + l.CellMargin
+}
+
+// weekdayRow returns the grid row of weekday d.
+func (l MapLayout) weekdayRow(d time.Weekday) int {
+	return (int(d) - int(l.WeekStart) + 7) % 7
+}
+
+// weekdayLabels returns the names of labeledWeekdays in grid row order, in
+// the layout's locale.
+func (l MapLayout) weekdayLabels() [7]string {
+	var labels [7]string
+	for _, d := range labeledWeekdays {
+		labels[l.weekdayRow(d)] = l.Locale.weekday(d)
+	}
+	return labels
+}
+
+// leftMargin returns the horizontal space reserved left of the grid for
+// weekday labels, or zero when they are disabled.
+func (l MapLayout) leftMargin() int {
+	if !l.WeekdayLabels {
+		return 0
+	}
+	return 3 * l.labelFontSize()
+}
+
+// topMargin returns the vertical space reserved above the grid for month labels.
+func (l MapLayout) topMargin() int {
+	return baseTopMargin * l.labelFontSize() / baseLabelFontSize
+}
+
+// titleHeight returns the vertical space reserved above the month labels for
+// the --title header, or zero when it is disabled.
+func (l MapLayout) titleHeight() int {
+	if !l.Title {
+		return 0
+	}
+	return 2 * l.titleFontSize()
+}
+
+// titleFontSize returns the --title font size, a little larger than the labels.
+func (l MapLayout) titleFontSize() int {
+	return l.labelFontSize() * 7 / 5
+}
+
+// generateCrossSVG produces an SVG “cross” diagram showing the breakdown of four contribution types.
+// The layout is as follows:
+//   - Top: Code Reviews
+//   - Bottom: Pull Requests
+//   - Left: Commits
+//   - Right: Issues
+//
+// In addition to printing the label and percentage at each arm, this function computes a weighted (x, y)
+// point (see crossDotPosition) and draws a large circle (dot) at that point. Colors come
+// from the theme: the background is the theme background, the dot uses the brightest
+// bucket and the text the mid-level bucket.
+func generateCrossSVG(crossData CrossData, outputFilename string, opts CrossOptions) error {
+	return writeSVGFile(outputFilename, renderCrossSVG(crossData, opts), opts.Gzip)
+}
+
+// renderCrossSVG returns the cross diagram SVG written by generateCrossSVG.
+func renderCrossSVG(crossData CrossData, opts CrossOptions) []byte {
+	crossData = crossData.weighted(opts.Weights)
+	var svg bytes.Buffer
+	writeSVGHeader(&svg, opts.Layout.Width, opts.Layout.Height, "Contribution breakdown", crossSummary(crossData))
+	opts.Theme.writeBackground(&svg, opts.Layout.Width, opts.Layout.Height)
+	writeCrossDiagram(&svg, crossData, opts)
+	svg.WriteString("</svg>")
+	return finishSVG(svg.Bytes(), opts.Minify, false)
+}
+
+// crossSummary describes the contribution breakdown for an accessible label.
+func crossSummary(crossData CrossData) string {
+	commitsPerc, prPerc, issuesPerc, codeReviewsPerc := crossData.percentages()
+	return fmt.Sprintf("Contribution breakdown: commits %0.1f%%, pull requests %0.1f%%, issues %0.1f%%, code reviews %0.1f%%", commitsPerc, prPerc, issuesPerc, codeReviewsPerc)
+}
+
+// writeCrossDiagram writes the axes, labels and dot of the cross diagram to
+// svg, positioned relative to the current origin. The background is left to
+// the caller.
+func writeCrossDiagram(svg *bytes.Buffer, crossData CrossData, opts CrossOptions) {
+	// Choose colors from the theme: the brightest bucket for the dot and the
+	// mid-level bucket for labels, unless that is hard to read on the background.
+	dot := opts.Theme.brightestBucket()
+	text := readableOn(opts.Theme.midBucket(), opts.Theme.Background)
+
+	layout := opts.Layout
+	centerX, centerY := layout.centerX(), layout.centerY()
+
+	// Draw dashed cross lines using the dot color.
+	svg.WriteString(fmt.Sprintf(`<line x1="%d" y1="0" x2="%d" y2="%d" stroke="%s" stroke-dasharray="4"/>`, centerX, centerX, layout.Height, dot))
+	svg.WriteString("\n")
+	svg.WriteString(fmt.Sprintf(`<line x1="0" y1="%d" x2="%d" y2="%d" stroke="%s" stroke-dasharray="4"/>`, centerY, layout.Width, centerY, dot))
+	svg.WriteString("\n")
+	arms := crossArms(crossData, opts)
+	for _, arm := range arms {
+		svg.WriteString(fmt.Sprintf(`<text x="%d" y="%d" text-anchor="middle" font-family="sans-serif" font-size="14px" fill="%s">%s</text>`, arm.X, arm.Y, text, escapeXML(arm.Label)))
+		svg.WriteString("\n")
+		svg.WriteString(fmt.Sprintf(`<text x="%d" y="%d" text-anchor="middle" font-family="sans-serif" font-size="12px" fill="%s">%0.1f%%</text>`, arm.X, arm.Y+18, text, arm.Perc))
+		svg.WriteString("\n")
+	}
+
+	// Compute the weighted (x, y) point.
+	x, y := crossDotPosition(arms, opts.Formula, layout)
+	// Draw a big circle (dot) at the computed point.
+	svg.WriteString(fmt.Sprintf(`<circle cx="%0.1f" cy="%0.1f" r="10" fill="%s"/>`, x, y, dot))
+	svg.WriteString("\n")
+}
+
+// generateCombinedSVG produces one SVG holding the contribution map (or the
+// stacked maps for several users) and the cross diagram on a shared
+// background, either side by side or, when stacked is set, one above the other.
+// The cross diagram is offset with a transform so its own coordinates are kept.
+func generateCombinedSVG(grids []LabeledWeeks, crossData CrossData, outputFilename string, mapOpts MapOptions, crossOpts CrossOptions, stacked bool) error {
+	var mapWidth, mapHeight int
+	var err error
+	if len(grids) == 1 {
+		mapWidth, mapHeight, err = mapGridSize(len(grids[0].Weeks), mapOpts)
+	} else {
+		mapWidth, mapHeight, err = multiMapSize(grids, mapOpts)
+	}
+	if err != nil {
+		return err
+	}
+
+	crossWidth, crossHeight := crossOpts.Layout.Width, crossOpts.Layout.Height
+	svgWidth, svgHeight := mapWidth+crossWidth, max(mapHeight, crossHeight)
+	crossX, crossY := mapWidth, 0
+	if stacked {
+		svgWidth, svgHeight = max(mapWidth, crossWidth), mapHeight+crossHeight
+		crossX, crossY = 0, mapHeight
+	}
+
+	summary := multiMapSummary(grids)
+	if len(grids) == 1 {
+		summary = mapSummary(grids[0].Weeks)
+	}
+	crossData = crossData.weighted(crossOpts.Weights)
+
+	var svg bytes.Buffer
+	writeSVGHeader(&svg, svgWidth, svgHeight, "Contributions", summary+". "+crossSummary(crossData))
+	mapOpts.Theme.writeBackground(&svg, svgWidth, svgHeight)
+	if len(grids) == 1 {
+		err = writeZeroCellDef(&svg, mapOpts)
+		if err == nil {
+			err = writeMapGrid(&svg, grids[0], mapOpts)
+		}
+	} else {
+		err = writeMultiMapGrid(&svg, grids, mapOpts)
+	}
+	if err != nil {
+		return err
+	}
+	svg.WriteString(fmt.Sprintf(`<g transform="translate(%d,%d)">`, crossX, crossY))
+	svg.WriteString("\n")
+	writeCrossDiagram(&svg, crossData, crossOpts)
+	svg.WriteString("</g>\n")
+	svg.WriteString("</svg>")
+	return writeSVGFile(outputFilename, finishSVG(svg.Bytes(), mapOpts.Minify, mapOpts.StripTooltips), mapOpts.Gzip)
+}
+
+// percentages returns each contribution type's share of the total, in percent.
+func (c CrossData) percentages() (commits, pullRequests, issues, codeReviews float64) {
+	total := c.Commits + c.PullRequests + c.Issues + c.CodeReviews
+	if total > 0 {
+		commits = float64(c.Commits) / float64(total) * 100
+		pullRequests = float64(c.PullRequests) / float64(total) * 100
+		issues = float64(c.Issues) / float64(total) * 100
+		codeReviews = float64(c.CodeReviews) / float64(total) * 100
+	}
+	return commits, pullRequests, issues, codeReviews
+}
+
+// crossDotPosition returns the weighted (x, y) point of the cross diagram dot
+// for arms in crossArms order, in a diagram laid out by layout.
+//
+// With crossFormulaAxes (the default) each axis is interpolated independently:
+//
+//	x = leftX + right/(left+right) * (rightX-leftX)
+//	y = topY + bottom/(top+bottom) * (bottomY-topY)
+//
+// where left, right, top and bottom are the counts of the types on those
+// arms, falling back to the center when an axis has no contributions. This
+// ignores how the horizontal pair compares with the vertical pair.
+//
+// With crossFormulaCentroid the dot is the centroid of the four arm endpoints
+// weighted by each type's share of the total:
+//
+//	x = centerX + (left*(leftX-centerX) + right*(rightX-centerX)) / total
+//	y = centerY + (top*(topY-centerY) + bottom*(bottomY-centerY)) / total
+//
+// so in the default layout a user with many commits and little else sits far
+// left and near the vertical center.
+func crossDotPosition(arms [4]crossArm, formula string, layout CrossLayout) (float64, float64) {
+	centerX, centerY := layout.centerX(), layout.centerY()
+	topY, bottomY, leftX, rightX := layout.topY(), layout.bottomY(), layout.leftX(), layout.rightX()
+	top, bottom, left, right := arms[crossArmTop].Count, arms[crossArmBottom].Count, arms[crossArmLeft].Count, arms[crossArmRight].Count
+	if formula == crossFormulaCentroid {
+		total := float64(top + bottom + left + right)
+		if total == 0 {
+			return float64(centerX), float64(centerY)
+		}
+		x := float64(centerX) + (float64(left)*float64(leftX-centerX)+float64(right)*float64(rightX-centerX))/total
+		y := float64(centerY) + (float64(top)*float64(topY-centerY)+float64(bottom)*float64(bottomY-centerY))/total
+		return x, y
+	}
+
+	var x, y float64
+	if (left + right) > 0 {
+		// x coordinate: interpolate from the left arm to the right one
+		x = float64(leftX) + (float64(right)/float64(left+right))*float64(rightX-leftX)
+	} else {
+		x = float64(centerX)
+	}
+	if (top + bottom) > 0 {
+		// y coordinate: interpolate from the top arm to the bottom one
+		y = float64(topY) + (float64(bottom)/float64(top+bottom))*float64(bottomY-topY)
+	} else {
+		y = float64(centerY)
+	}
+	return x, y
+}
+
+// fetchConfig holds everything needed to fetch one user's contributions from
+// the selected platform.
+type fetchConfig struct {
+	Platform         string // github, gitea, forgejo, codeberg or bitbucket
+	GitHubURL        string
+	BitbucketURL     string
+	GiteaURL         string // also used for forgejo and codeberg
+	GiteaURLExplicit bool   // GiteaURL was given by the user rather than defaulted
+	EventsPath       string // giteaEventsPath or forgejoEventsPath
+	Token            string
+	Location         *time.Location
+	LightMode        bool
+}
+
+// forPlatform returns a copy of c set up for the named platform, e.g. with
+// the Forgejo event feed, or codeberg.org unless a URL was given.
+func (c fetchConfig) forPlatform(platform string) (fetchConfig, error) {
+	c.Platform = strings.ToLower(platform)
+	p, ok := platforms[c.Platform]
+	if !ok {
+		return fetchConfig{}, fmt.Errorf("Unknown platform: %s. Available platforms: %s.", platform, strings.Join(platformNames(), ", "))
+	}
+	p.Configure(&c)
+	return c, nil
+}
+
+// instance identifies the server (and, for platforms bucketing events into
+// days themselves, the time zone) for cache keys.
+func (c fetchConfig) instance() string {
+	return platforms[c.Platform].Instance(c)
+}
+
+// fetch retrieves the contributions of username, announcing the fetch on
+// stdout. The total is GitHub's own yearly total, or the sum of the daily
+// counts on platforms that do not report one.
+func (c fetchConfig) fetch(ctx context.Context, username string) (Weeks, CrossData, int, error) {
+	weeks, crossData, total, _, err := c.fetchIfNoneMatch(ctx, username, "")
+	return weeks, crossData, total, err
+}
+
+// fetchIfNoneMatch is fetch revalidating a cached fetch with the given ETag
+// where the platform is a revalidator: it returns errNotModified if the
+// contributions are unchanged, and otherwise the new ETag with them. Other
+// platforms ignore etag and return none.
+func (c fetchConfig) fetchIfNoneMatch(ctx context.Context, username, etag string) (Weeks, CrossData, int, string, error) {
+	p := platforms[c.Platform]
+	progress.clear()
+	if server := p.Server(c); server != "" {
+		fmt.Fprintf(statusOut, "Fetching contributions for %s user %s from %s...\n", p.Title(), username, server)
+	} else {
+		fmt.Fprintf(statusOut, "Fetching contributions for %s user %s...\n", p.Title(), username)
+	}
+	var weeks Weeks
+	var crossData CrossData
+	var total int
+	var err error
+	if r, ok := p.(revalidator); ok {
+		weeks, crossData, total, etag, err = r.FetchIfNoneMatch(ctx, c, username, etag)
+	} else {
+		weeks, crossData, total, err = p.Fetch(ctx, c, username)
+		etag = ""
+	}
+	if err != nil {
+		return nil, CrossData{}, 0, "", fmt.Errorf("Error fetching %s contributions for %s: %w", p.Title(), username, err)
+	}
+	return weeks, crossData, total, etag, nil
+}
+
+// fetchWindow is fetch for the days of window rather than the trailing year.
+// The platform's Source must be a windowFetcher.
+func (c fetchConfig) fetchWindow(ctx context.Context, username string, window dateWindow) (Weeks, CrossData, int, error) {
+	p := platforms[c.Platform]
+	fmt.Fprintf(statusOut, "Fetching contributions for %s user %s from %s...\n", p.Title(), username, window)
+	weeks, crossData, total, err := p.(windowFetcher).FetchWindow(ctx, c, username, window)
+	if err != nil {
+		return nil, CrossData{}, 0, fmt.Errorf("Error fetching %s contributions for %s from %s: %w", p.Title(), username, window, err)
+	}
+	return weeks, crossData, total, nil
+}
+
+// userFetch is the outcome of fetching one user in fetchUsers.
+type userFetch struct {
+	Name      string
+	Weeks     Weeks
+	CrossData CrossData
+	Total     int
+	Cached    bool
+	Err       error
+}
+
+// fetchUsers runs fetchOne for every name on at most concurrency workers and
+// returns the results in the order of names. Canceling ctx stops the
+// in-flight fetches, which then report the cancellation as their error. With
+// stopOnError, the first failure also cancels the fetches still in flight and
+// skips the rest, and every result cut short reports that failure instead.
+func fetchUsers(ctx context.Context, names []string, concurrency int, stopOnError bool, fetchOne func(context.Context, string) userFetch) []userFetch {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	results := make([]userFetch, len(names))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency && w < len(names); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if ctx.Err() != nil {
+					results[i] = userFetch{Name: names[i], Err: context.Cause(ctx)}
+					continue
+				}
+				results[i] = fetchOne(ctx, names[i])
+				if results[i].Err != nil && stopOnError {
+					cancel(results[i].Err)
+				}
+			}
+		}()
+	}
+	for i := range names {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	if cause := context.Cause(ctx); stopOnError && cause != nil {
+		for i := range results {
+			if errors.Is(results[i].Err, context.Canceled) {
+				results[i].Err = cause
+			}
+		}
+	}
+	return results
+}
+
+// splitUsers splits a comma-separated --user value into trimmed, non-empty names.
+func splitUsers(value string) []string {
+	var users []string
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			users = append(users, name)
+		}
+	}
+	return users
+}
+
+// =============================================================================
+// Main (using mow.cli)
+// =============================================================================
+
+func main() {
+	app := cli.App("contribmap", "Generate a contribution map (heatmap) and a cross diagram showing contribution breakdowns for GitHub or Gitea users.")
+	app.LongDesc = "Generate a contribution map (heatmap) and a cross diagram showing contribution breakdowns for GitHub or Gitea users.\n\n" + exitStatusHelp
+	app.Version("version", versionString())
+
+	// Read by withConfigArgs before the options are parsed; declared so that
+	// it is accepted and listed in --help.
+	app.String(cli.StringOpt{
+		Name:  "config",
+		Value: "",
+		Desc:  "Configuration file of option defaults as name = value lines (flat TOML or YAML), overridden option by option by the command line; defaults to config.yaml or config.toml in the user's contribmap config directory (a file here is only read when named), and --config \"\" disables it",
+	})
+	platform := app.String(cli.StringOpt{
+		Name:  "platform",
+		Value: "github",
+		Desc:  "Platform to use: github, gitea, forgejo, codeberg (Forgejo at codeberg.org unless --gitea-url is given) or bitbucket",
+	})
+	user := app.String(cli.StringOpt{
+		Name: "user",
+		Desc: "Username on the chosen platform (on GitHub, defaults to the account the token belongs to); a comma-separated list renders one labeled map per user",
+	})
+	token := app.String(cli.StringOpt{
+		Name:   "token",
+		EnvVar: "CONTRIBMAP_TOKEN",
+		Desc:   "API token (required for GitHub; optional for Gitea, sent as 'Authorization: token' for private instances or activity; for Bitbucket an app password as username:app-password, or an access token)",
+	})
+	githubURL := app.String(cli.StringOpt{
+		Name:  "github-url",
+		Value: githubGraphQLEndpoint,
+		Desc:  "GitHub GraphQL endpoint, e.g. https://ghe.example.com/api/graphql for GitHub Enterprise Server",
+	})
+	bitbucketURL := app.String(cli.StringOpt{
+		Name:  "bitbucket-url",
+		Value: defaultBitbucketURL,
+		Desc:  "Bitbucket REST API base; other instances must serve the Cloud 2.0 API (the Data Center 1.0 API is not supported)",
+	})
+	var giteaURLSet bool
+	giteaURL := app.String(cli.StringOpt{
+		Name:      "gitea-url",
+		SetByUser: &giteaURLSet,
+		Value:     "https://try.gitea.io",
+		Desc:      "Base URL for Gitea or Forgejo instance, https:// unless another scheme is given (used if platform is gitea, forgejo or codeberg)",
+	})
+	lightMode := app.Bool(cli.BoolOpt{
+		Name:  "light-mode",
+		Value: false,
+		Desc:  "Use the light color scheme for both the map and cross diagram (default is dark mode)",
+	})
+	mode := app.String(cli.StringOpt{
+		Name:  "mode",
+		Value: "",
+		Desc:  "Color scheme: light, dark, or auto for an SVG map that follows the viewer's prefers-color-scheme (other outputs are dark); replaces --light-mode",
+	})
+	var mapLightModeSet, crossLightModeSet bool
+	mapLightMode := app.Bool(cli.BoolOpt{
+		Name:      "map-light-mode",
+		SetByUser: &mapLightModeSet,
+		Value:     false,
+		Desc:      "Light color scheme for the map only, e.g. --map-light-mode=false; defaults to --light-mode",
+	})
+	crossLightMode := app.Bool(cli.BoolOpt{
+		Name:      "cross-light-mode",
+		SetByUser: &crossLightModeSet,
+		Value:     false,
+		Desc:      "Light color scheme for the cross diagram only; defaults to --light-mode",
+	})
+	outputFormat := app.String(cli.StringOpt{
+		Name:  "output",
+		Value: "svg",
+		Desc:  "Output format: svg, svgz (svg, gzip-compressed), pdf (map and cross diagram as pages of one file), html (both on one page, with hover tooltips), webp (rasterized, without text labels), csv (daily counts only), json (the fetched data, for --input), sparkline (a small SVG line of monthly totals) or badge (the total next to one cell colored by how many days were active)",
+	})
+	sparklineWidth := app.Int(cli.IntOpt{
+		Name:  "sparkline-width",
+		Value: defaultSparklineWidth,
+		Desc:  "Width in pixels of --output sparkline",
+	})
+	sparklineDots := app.Bool(cli.BoolOpt{
+		Name:  "sparkline-dots",
+		Value: false,
+		Desc:  "Mark the lowest and highest month on --output sparkline",
+	})
+	input := app.String(cli.StringOpt{
+		Name: "input",
+		Desc: "Render from a file written by --output json (or a cache entry) instead of fetching; no --user or token is needed",
+	})
+	granularity := app.String(cli.StringOpt{
+		Name:  "granularity",
+		Value: granularityDaily,
+		Desc:  "Map view: daily (the heatmap), or weekly or monthly for a bar chart of contribution totals (svg output)",
+	})
+	view := app.String(cli.StringOpt{
+		Name:  "view",
+		Value: viewStrip,
+		Desc:  "Map layout: strip (the continuous 53-week heatmap) or calendar-months (twelve mini month calendars; svg output)",
+	})
+	themeName := app.String(cli.StringOpt{
+		Name: "theme",
+		Desc: "Color theme: a built-in name (dark, light, github, dracula, solarized) or a JSON theme file (default follows --light-mode)",
+	})
+	background := app.String(cli.StringOpt{
+		Name: "background",
+		Desc: "transparent (or none) to draw no background, letting the page show through; text colors still follow --light-mode or --color background=#hex (svg and pdf output)",
+	})
+	colors := app.Strings(cli.StringsOpt{
+		Name: "color",
+		Desc: "Override a theme color as key=#hex, where key is background, zero or bucket1..bucketN for N --buckets (repeatable)",
+	})
+	cellSize := app.Int(cli.IntOpt{
+		Name:  "cell-size",
+		Value: defaultCellSize,
+		Desc:  "Size in pixels of each day cell in the contribution map",
+	})
+	cellMargin := app.Int(cli.IntOpt{
+		Name:  "cell-margin",
+		Value: defaultCellMargin,
+		Desc:  "Gap in pixels between day cells in the contribution map",
+	})
+	continueOnError := app.Bool(cli.BoolOpt{
+		Name:  "continue-on-error",
+		Value: false,
+		Desc:  "With several users, skip users whose fetch fails instead of exiting; with --range, leave the years that fail empty",
+	})
+	concurrency := app.Int(cli.IntOpt{
+		Name:  "concurrency",
+		Value: 4,
+		Desc:  "With several users, how many to fetch at the same time; with --range, how many years of a user",
+	})
+	uniformScale := app.Bool(cli.BoolOpt{
+		Name:  "uniform-scale",
+		Value: false,
+		Desc:  "With several users, color every map on one shared scale instead of per user",
+	})
+	weekdayLabels := app.Bool(cli.BoolOpt{
+		Name:  "weekday-labels",
+		Value: false,
+		Desc:  "Draw Mon/Wed/Fri labels to the left of the contribution map",
+	})
+	highlightStreak := app.Bool(cli.BoolOpt{
+		Name:  "highlight-streak",
+		Value: false,
+		Desc:  "Outline the longest contribution streak on the map and note its length",
+	})
+	goal := app.Int(cli.IntOpt{
+		Name:  "goal",
+		Value: 0,
+		Desc:  "Daily contribution goal: mark the days that reach it on the map (SVG output) and report how often it was met",
+	})
+	compareTo := app.String(cli.StringOpt{
+		Name:  "compare-to",
+		Value: "",
+		Desc:  "Also fetch this earlier window as FROM:TO, e.g. 2022-01-01:2022-12-31, and summarize how the last year compares: totals, contribution types and months improved (GitHub only; included in --output json)",
+	})
+	dateRange := app.String(cli.StringOpt{
+		Name:  "range",
+		Value: "",
+		Desc:  "Map this window as FROM:TO instead of the last year, e.g. 2015-01-01:2024-12-31; longer windows are fetched a year at a time, --concurrency years at once (GitHub only)",
+	})
+	cellLabels := app.Bool(cli.BoolOpt{
+		Name:  "cell-labels",
+		Value: false,
+		Desc:  "Write the contribution count inside each nonzero cell (SVG output; needs a cell size of 12 or more)",
+	})
+	animate := app.Bool(cli.BoolOpt{
+		Name:  "animate",
+		Value: false,
+		Desc:  "Fade the map cells in week by week with SMIL animation (SVG output); the last frame is the normal map",
+	})
+	animateDuration := app.String(cli.StringOpt{
+		Name:  "animate-duration",
+		Value: "3s",
+		Desc:  "How long the --animate sweep takes, e.g. 3s or 1500ms",
+	})
+	shadeWeekends := app.Bool(cli.BoolOpt{
+		Name:  "shade-weekends",
+		Value: false,
+		Desc:  "Tint the Saturday and Sunday rows of the map behind the cells (SVG output)",
+	})
+	link := app.Bool(cli.BoolOpt{
+		Name:  "link",
+		Value: false,
+		Desc:  "Make each nonzero map cell a link to that day's contributions on the platform (SVG and HTML output)",
+	})
+	trimEmpty := app.Bool(cli.BoolOpt{
+		Name:  "trim-empty",
+		Value: false,
+		Desc:  "Drop the weeks without contributions before the first active week and after the last, e.g. from before a new account existed; the map and the summary then cover the remaining weeks only",
+	})
+	wrap := app.Int(cli.IntOpt{
+		Name:  "wrap",
+		Value: 1,
+		Desc:  "Wrap the weeks of the map into this many rows, e.g. 2 for two rows of about 26 weeks",
+	})
+	rounded := app.Bool(cli.BoolOpt{
+		Name:  "rounded",
+		Value: false,
+		Desc:  "Draw the map cells as rounded rectangles (SVG output)",
+	})
+	minify := app.Bool(cli.BoolOpt{
+		Name:  "minify",
+		Value: false,
+		Desc:  "Write SVGs without the whitespace between elements, for smaller files",
+	})
+	stripTooltips := app.Bool(cli.BoolOpt{
+		Name:  "strip-tooltips",
+		Value: false,
+		Desc:  "Leave the per-day <title> tooltips out of the map SVG (cells keep their aria-label); pairs well with --minify",
+	})
+	richTooltips := app.Bool(cli.BoolOpt{
+		Name:  "rich-tooltips",
+		Value: false,
+		Desc:  "Name the weekday in each day's map tooltip, e.g. \"Sun 2025-11-30: 2 contributions\" (SVG and HTML output)",
+	})
+	templateFile := app.String(cli.StringOpt{
+		Name: "template",
+		Desc: "Render the map SVG with this Go text/template file instead of the built-in one, which it may reuse parts of such as {{template \"grid\" .}}; 'default' names the built-in template itself (svg output, one user)",
+	})
+	weekStart := app.String(cli.StringOpt{
+		Name:  "week-start",
+		Value: "sunday",
+		Desc:  "First day of each map column: sunday (as on GitHub) or monday (ISO weeks)",
+	})
+	localeOpt := app.String(cli.StringOpt{
+		Name:  "locale",
+		Value: "en",
+		Desc:  "Language of the month and weekday labels: de, en, es, fr, it, ja, ko, nl, pl, pt, ru, sv or zh, also as e.g. pt_BR; others fall back to English",
+	})
+	title := app.Bool(cli.BoolOpt{
+		Name:  "title",
+		Value: false,
+		Desc:  "Add a header such as \"1,234 contributions in the last year\" above the map (svg and pdf output)",
+	})
+	weights := app.Strings(cli.StringsOpt{
+		Name:  "weight",
+		Value: nil,
+		Desc:  "Weight contribution types in the cross diagram, e.g. commits=1,reviews=3 (types: commits, prs, issues, reviews; default 1 each)",
+	})
+	crossWidth := app.Int(cli.IntOpt{
+		Name:  "cross-width",
+		Value: defaultCrossSize,
+		Desc:  "Width in pixels of the cross diagram; the arm labels move with it",
+	})
+	crossHeight := app.Int(cli.IntOpt{
+		Name:  "cross-height",
+		Value: defaultCrossSize,
+		Desc:  "Height in pixels of the cross diagram",
+	})
+	crossLabels := app.Strings(cli.StringsOpt{
+		Name:  "cross-label",
+		Value: nil,
+		Desc:  "Rename an arm of the cross diagram as type=text, e.g. commits=Commits (types as for --weight; repeatable)",
+	})
+	crossSort := app.Bool(cli.BoolOpt{
+		Name:  "cross-sort",
+		Value: false,
+		Desc:  "Arrange the cross diagram arms by count, the largest on top and the rest clockwise, instead of the fixed layout (reviews top, pull requests bottom, commits left, issues right)",
+	})
+	crossFormula := app.String(cli.StringOpt{
+		Name:  "cross-formula",
+		Value: crossFormulaAxes,
+		Desc:  "Cross diagram dot placement: axes (commits vs issues, reviews vs pull requests) or centroid (share-weighted centroid of all four arms)",
+	})
+	combined := app.Bool(cli.BoolOpt{
+		Name:  "combined",
+		Value: false,
+		Desc:  "Write the contribution map and cross diagram into one SVG (svg output only)",
+	})
+	combinedLayout := app.String(cli.StringOpt{
+		Name:  "combined-layout",
+		Value: "side-by-side",
+		Desc:  "Arrangement for --combined: side-by-side or stacked",
+	})
+	mapOutput := app.String(cli.StringOpt{
+		Name: "map-output",
+		Desc: "Path for the contribution map (default contributions.<format>), or --map-output=- for stdout; parent directories are created",
+	})
+	crossOutput := app.String(cli.StringOpt{
+		Name: "cross-output",
+		Desc: "Path for the cross diagram (default contributions_cross.<format>), or --cross-output=- for stdout; parent directories are created",
+	})
+	noMap := app.Bool(cli.BoolOpt{
+		Name:  "no-map",
+		Value: false,
+		Desc:  "Skip generating the contribution map",
+	})
+	noCross := app.Bool(cli.BoolOpt{
+		Name:  "no-cross",
+		Value: false,
+		Desc:  "Skip generating the cross diagram",
+	})
+	cacheDir := app.String(cli.StringOpt{
+		Name:  "cache-dir",
+		Value: defaultCacheDir(),
+		Desc:  "Directory for cached fetch results",
+	})
+	cacheTTL := app.String(cli.StringOpt{
+		Name:  "cache-ttl",
+		Value: defaultCacheTTL.String(),
+		Desc:  "How long cached fetch results are reused, e.g. 30m or 2h",
+	})
+	noCache := app.Bool(cli.BoolOpt{
+		Name:  "no-cache",
+		Value: false,
+		Desc:  "Always fetch from the API and do not write the cache",
+	})
+	timezone := app.String(cli.StringOpt{
+		Name:  "timezone",
+		Value: "UTC",
+		Desc:  "IANA time zone used to assign Gitea events to days, e.g. America/New_York",
+	})
+	serve := app.String(cli.StringOpt{
+		Name: "serve",
+		Desc: "Instead of writing files, serve HTTP at this address, e.g. :8080: /map and /cross render SVGs on demand (?user=X), /metrics has Prometheus gauges for --user",
+	})
+	refresh := app.String(cli.StringOpt{
+		Name:  "refresh",
+		Value: "15m",
+		Desc:  "How often --serve refetches contributions",
+	})
+	openOutput := app.Bool(cli.BoolOpt{
+		Name:  "open",
+		Value: false,
+		Desc:  "Open the written files in the system's default viewer (xdg-open, open or start); skipped when the CI environment variable is set and for stdout",
+	})
+	quiet := app.Bool(cli.BoolOpt{
+		Name:  "quiet",
+		Value: false,
+		Desc:  "Print nothing but errors and warnings (no progress, saved-file or summary lines)",
+	})
+	check := app.Bool(cli.BoolOpt{
+		Name:  "check",
+		Value: false,
+		Desc:  "Only check that the token is accepted and report the rate limit, then exit (nonzero on failure)",
+	})
+	verbose := app.Bool(cli.BoolOpt{
+		Name:  "verbose",
+		Value: false,
+		Desc:  "Log requests, responses and computed totals to stderr",
+	})
+	dumpQuery := app.Bool(cli.BoolOpt{
+		Name:  "dump-query",
+		Value: false,
+		Desc:  "Print each GitHub GraphQL query and its variables to stderr as sent (the token is not included), e.g. to try them in GitHub's GraphQL Explorer",
+	})
+	proxy := app.String(cli.StringOpt{
+		Name:  "proxy",
+		Value: "",
+		Desc:  "Send API requests through this proxy, e.g. http://proxy.example.com:3128, instead of the one from HTTP_PROXY/HTTPS_PROXY/NO_PROXY",
+	})
+	userAgentOpt := app.String(cli.StringOpt{
+		Name:  "user-agent",
+		Value: "",
+		Desc:  "User-Agent header for API requests (default contribmap/<version>)",
+	})
+	caCert := app.String(cli.StringOpt{
+		Name:  "ca-cert",
+		Value: "",
+		Desc:  "PEM file of CA certificates to trust in addition to the system ones, e.g. for a Gitea instance behind an internal CA",
+	})
+	insecureSkipVerify := app.Bool(cli.BoolOpt{
+		Name:  "insecure-skip-verify",
+		Value: false,
+		Desc:  "Do not verify TLS certificates (e.g. a self-signed Gitea instance); anyone on the network path can read the token",
+	})
+	jsonErrorsOpt := app.Bool(cli.BoolOpt{
+		Name:  "json-errors",
+		Value: false,
+		Desc:  `On failure print {"error": "...", "code": "..."} to stdout instead of text to stderr; codes: usage, auth, not_found, rate_limit, network, error (see the exit status above)`,
+	})
+	buckets := app.Int(cli.IntOpt{
+		Name:  "buckets",
+		Value: defaultBucketCount,
+		Desc:  fmt.Sprintf("Number of color levels for active days (%d-%d); other counts interpolate the theme palette", minBucketCount, maxBucketCount),
+	})
+	gradientFrom := app.String(cli.StringOpt{
+		Name:  "gradient-from",
+		Value: "",
+		Desc:  "Generate the bucket colors from this #hex for the fewest contributions to --gradient-to for the most, instead of the theme's",
+	})
+	gradientTo := app.String(cli.StringOpt{
+		Name:  "gradient-to",
+		Value: "",
+		Desc:  "End color of the --gradient-from palette, used for the busiest days and the cross diagram",
+	})
+	maxCount := app.Int(cli.IntOpt{
+		Name:  "max-count",
+		Value: 0,
+		Desc:  "Pin the daily count that gets the brightest color instead of using the busiest day, so colors mean the same across runs and users",
+	})
+	continuous := app.Bool(cli.BoolOpt{
+		Name:  "continuous",
+		Value: false,
+		Desc:  "Shade active days by the opacity of one color, in proportion to the busiest day, instead of in buckets",
+	})
+	recencyDecay := app.Int(cli.IntOpt{
+		Name:  "recency-decay",
+		Value: 0,
+		Desc:  "Experimental: fade older days in the colors, halving a day's weight for every this many days before the last one, so the map shows recent momentum; tooltips keep the real counts (0 turns it off)",
+	})
+	countThreshold := app.Int(cli.IntOpt{
+		Name:  "count-threshold",
+		Value: 0,
+		Desc:  "Color days with fewer contributions than this as empty, e.g. 3 to hide days of a single automated commit; tooltips and totals keep the real counts (0 turns it off)",
+	})
+	scale := app.String(cli.StringOpt{
+		Name:  "scale",
+		Value: scaleLinear,
+		Desc:  "Color scale: linear (even buckets up to the busiest day) or quantile (buckets by percentile of active days)",
+	})
+
+	app.Action = func() {
+		if *verbose {
+			verboseLog.SetOutput(os.Stderr)
+		}
+		if *dumpQuery {
+			queryDump = os.Stderr
+		}
+		jsonErrors = *jsonErrorsOpt
+		if *outputFormat != "svg" && *outputFormat != "svgz" && *outputFormat != "pdf" && *outputFormat != "webp" && *outputFormat != "csv" && *outputFormat != "json" && *outputFormat != "sparkline" && *outputFormat != "html" && *outputFormat != "badge" {
+			fail(errCodeUsage, "Unknown output format: %s. Use 'svg', 'svgz', 'pdf', 'html', 'webp', 'csv', 'json', 'sparkline' or 'badge'.", *outputFormat)
+		}
+		// svgz is the svg output compressed as it is written, so from here on
+		// it is svg but for the file extension.
+		outputExtension := *outputFormat
+		svgz := *outputFormat == "svgz"
+		if svgz {
+			*outputFormat = "svg"
+		}
+		if *scale != scaleLinear && *scale != scaleQuantile {
+			fail(errCodeUsage, "Unknown scale: %s. Use 'linear' or 'quantile'.", *scale)
+		}
+		if *buckets < minBucketCount || *buckets > maxBucketCount {
+			fail(errCodeUsage, "Invalid bucket count: %d. Use %d to %d.", *buckets, minBucketCount, maxBucketCount)
+		}
+		if *continuous {
+			if *scale != scaleLinear {
+				fail(errCodeUsage, "--continuous replaces the bucketed --scale; use one or the other.")
+			}
+			*scale = scaleContinuous
+		}
+		if *maxCount < 0 || (*maxCount > 0 && *scale == scaleQuantile) {
+			fail(errCodeUsage, "Invalid --max-count: %d. Use a daily count of 1 or more, with the linear scale or --continuous.", *maxCount)
+		}
+		if *recencyDecay < 0 {
+			fail(errCodeUsage, "Invalid --recency-decay: %d. Use a half-life of 1 or more days, or 0 to turn it off.", *recencyDecay)
+		}
+		if *countThreshold < 0 {
+			fail(errCodeUsage, "Invalid --count-threshold: %d. Use a daily count of 1 or more, or 0 to turn it off.", *countThreshold)
+		}
+		colorScale := ColorScale{Kind: *scale, MaxCount: *maxCount, HalfLife: *recencyDecay, Floor: *countThreshold}
+		cacheTTLValue, err := time.ParseDuration(*cacheTTL)
+		if err != nil || cacheTTLValue < 0 {
+			fail(errCodeUsage, "Invalid cache TTL: %s. Use a duration such as 30m or 2h.", *cacheTTL)
+		}
+		location, err := time.LoadLocation(*timezone)
+		if err != nil {
+			fail(errCodeUsage, "Unknown time zone: %s", *timezone)
+		}
+		if *noMap && *noCross {
+			fail(errCodeUsage, "--no-map and --no-cross together leave nothing to generate.")
+		}
+		if *combined && (*noMap || *noCross) {
+			fail(errCodeUsage, "--combined cannot be used with --no-map or --no-cross.")
+		}
+		if *combined && *outputFormat != "svg" {
+			fail(errCodeUsage, "--combined is only supported with svg output.")
+		}
+		if *granularity != granularityDaily && *granularity != granularityWeekly && *granularity != granularityMonthly {
+			fail(errCodeUsage, "Unknown granularity: %s. Use 'daily', 'weekly' or 'monthly'.", *granularity)
+		}
+		if *granularity != granularityDaily && (*outputFormat != "svg" || *combined) {
+			fail(errCodeUsage, "--granularity weekly and monthly are only supported with svg output and without --combined.")
+		}
+		if *view != viewStrip && *view != viewCalendarMonths {
+			fail(errCodeUsage, "Unknown view: %s. Use 'strip' or 'calendar-months'.", *view)
+		}
+		if *view != viewStrip && (*outputFormat != "svg" || *combined || *granularity != granularityDaily) {
+			fail(errCodeUsage, "--view calendar-months is only supported with svg output, without --combined and with daily granularity.")
+		}
+		if *mapOutput == "-" && *crossOutput == "-" && !*noMap && !*noCross && *outputFormat != "pdf" && *outputFormat != "html" && *outputFormat != "csv" && *outputFormat != "sparkline" && *outputFormat != "badge" {
+			fail(errCodeUsage, "Only one of --map-output and --cross-output can be - (stdout).")
+		}
+		// Keep stdout for the output or the --json-errors object alone.
+		if *mapOutput == "-" || *crossOutput == "-" || jsonErrors {
+			statusOut = os.Stderr
+		}
+		if *quiet {
+			statusOut = io.Discard
+		}
+		// The progress line would be torn up by --verbose logging or
+		// --dump-query, and is only useful to someone watching a terminal.
+		if !*quiet && !*verbose && !*dumpQuery && stderrIsTerminal() {
+			progress = &fetchProgress{w: os.Stderr}
+		}
+		if *combinedLayout != "side-by-side" && *combinedLayout != "stacked" {
+			fail(errCodeUsage, "Unknown combined layout: %s. Use 'side-by-side' or 'stacked'.", *combinedLayout)
+		}
+		if *crossFormula != crossFormulaAxes && *crossFormula != crossFormulaCentroid {
+			fail(errCodeUsage, "Unknown cross formula: %s. Use 'axes' or 'centroid'.", *crossFormula)
+		}
+		// Each artifact follows --mode or --light-mode unless its own flag is given.
+		baseLight := *lightMode
+		switch *mode {
+		case "":
+		case "light":
+			baseLight = true
+		case "dark", "auto":
+			baseLight = false
+		default:
+			fail(errCodeUsage, "Unknown mode: %s. Use 'light', 'dark' or 'auto'.", *mode)
+		}
+		mapLight, crossLight := baseLight, baseLight
+		if mapLightModeSet {
+			mapLight = *mapLightMode
+		}
+		if crossLightModeSet {
+			crossLight = *crossLightMode
+		}
+		if (*gradientFrom == "") != (*gradientTo == "") {
+			fail(errCodeUsage, "--gradient-from and --gradient-to must be given together.")
+		}
+		gradient := [2]string{*gradientFrom, *gradientTo}
+		theme, err := resolveTheme(*themeName, mapLight, gradient, *buckets, *colors)
+		if err != nil {
+			fail(errCodeUsage, "Invalid theme: %v", err)
+		}
+		if *combined && crossLight != mapLight {
+			fail(errCodeUsage, "--combined draws both on one background, so --map-light-mode and --cross-light-mode must match.")
+		}
+		crossTheme := theme
+		if crossLight != mapLight {
+			crossTheme, _ = resolveTheme(*themeName, crossLight, gradient, *buckets, *colors)
+		}
+		switch strings.ToLower(*background) {
+		case "":
+		case "transparent", "none":
+			if *outputFormat == "webp" {
+				fail(errCodeUsage, "--background transparent is not supported with webp output.")
+			}
+			theme.Transparent = true
+			crossTheme.Transparent = true
+		default:
+			fail(errCodeUsage, "Unknown background: %s. Use 'transparent', or set a color with --color background=#hex.", *background)
+		}
+		layout := MapLayout{CellSize: *cellSize, CellMargin: *cellMargin, WeekdayLabels: *weekdayLabels, Rounded: *rounded, Wrap: *wrap, Title: *title}
+		switch strings.ToLower(*weekStart) {
+		case "sunday":
+			layout.WeekStart = time.Sunday
+		case "monday":
+			layout.WeekStart = time.Monday
+		default:
+			fail(errCodeUsage, "Unknown week start: %s. Use 'sunday' or 'monday'.", *weekStart)
+		}
+		if locale, ok := lookupLocale(*localeOpt); ok {
+			layout.Locale = locale
+		} else {
+			fmt.Fprintf(os.Stderr, "Warning: unknown locale %s; using English month and weekday names.\n", *localeOpt)
+		}
+		if err := layout.validate(); err != nil {
+			fail(errCodeUsage, "Invalid map layout: %v", err)
+		}
+
+		baseCfg := fetchConfig{
+			GitHubURL:        *githubURL,
+			BitbucketURL:     strings.TrimSuffix(*bitbucketURL, "/"),
+			GiteaURL:         *giteaURL,
+			GiteaURLExplicit: giteaURLSet,
+			Token:            *token,
+			Location:         location,
+			LightMode:        mapLight,
+		}
+		fetchCfg, err := baseCfg.forPlatform(*platform)
+		if err != nil {
+			fail(errCodeUsage, "%v", err)
+		}
+		platformName := fetchCfg.Platform
+		if platformName == "github" {
+			if err := validateEndpointURL(*githubURL); err != nil {
+				fail(errCodeUsage, "Invalid --github-url: %v", err)
+			}
+		}
+		if platformName == "bitbucket" {
+			if err := validateEndpointURL(*bitbucketURL); err != nil {
+				fail(errCodeUsage, "Invalid --bitbucket-url: %v", err)
+			}
+		}
+		if fetchCfg.EventsPath != "" {
+			normalized, err := normalizeBaseURL(fetchCfg.GiteaURL)
+			if err != nil {
+				fail(errCodeUsage, "Invalid --gitea-url: %v", err)
+			}
+			if normalized != fetchCfg.GiteaURL {
+				verboseLog.Printf("Using %s as the %s base URL", normalized, platforms[platformName].Title())
+			}
+			fetchCfg.GiteaURL = normalized
+		}
+		if *proxy != "" {
+			proxyURL, err := url.Parse(*proxy)
+			if err != nil || proxyURL.Host == "" || (proxyURL.Scheme != "http" && proxyURL.Scheme != "https" && proxyURL.Scheme != "socks5") {
+				fail(errCodeUsage, "Invalid --proxy: %s. Use a URL such as http://proxy.example.com:3128.", *proxy)
+			}
+			httpTransport.Proxy = http.ProxyURL(proxyURL)
+			verboseLog.Printf("Using proxy %s", proxyURL.Redacted())
+		}
+		if *userAgentOpt != "" {
+			userAgent = *userAgentOpt
+		}
+		if *caCert != "" || *insecureSkipVerify {
+			tlsConfig, err := loadTLSConfig(*caCert, *insecureSkipVerify)
+			if err != nil {
+				fail(errCodeUsage, "Invalid --ca-cert: %v", err)
+			}
+			httpTransport.TLSClientConfig = tlsConfig
+			if *insecureSkipVerify {
+				fmt.Fprintln(os.Stderr, "Warning: TLS certificate verification is disabled (--insecure-skip-verify); the connection and token are not protected against interception.")
+			}
+		}
+		// Without --user, GitHub maps the account the token belongs to.
+		if len(splitUsers(*user)) == 0 && *serve == "" && !*check && *input == "" && (platformName != "github" || *token == "") {
+			fail(errCodeUsage, "Please provide a username using the --user option.")
+		}
+		if platformName == "github" && *token == "" && *serve == "" && *input == "" {
+			fail(errCodeAuth, "A GitHub token is required when using the GitHub platform. Provide it using the --token option or the CONTRIBMAP_TOKEN environment variable.")
+		}
+		mapOpts := MapOptions{LightMode: mapLight, Theme: theme, Layout: layout, HighlightStreak: *highlightStreak, Goal: *goal, CellLabels: *cellLabels, ShadeWeekends: *shadeWeekends, Minify: *minify, StripTooltips: *stripTooltips, Gzip: svgz, RichTooltips: *richTooltips}
+		if *mode == "auto" && !mapLight {
+			lightTheme, _ := resolveTheme(*themeName, true, gradient, *buckets, *colors)
+			mapOpts.AutoLight = &lightTheme
+		}
+		if *link {
+			linker, ok := platforms[platformName].(dayLinker)
+			if !ok {
+				fail(errCodeUsage, "--link is not supported on %s, which has no page for a day's contributions.", platforms[platformName].Title())
+			}
+			mapOpts.DayLink = func(user, date string) string { return linker.DayURL(fetchCfg, user, date) }
+		}
+		if *templateFile != "" {
+			if *outputFormat != "svg" || *combined || *granularity != granularityDaily || *view != viewStrip {
+				fail(errCodeUsage, "--template only applies to the svg contribution map, without --combined, --granularity or --view.")
+			}
+			mapOpts.Template, err = loadMapTemplate(*templateFile)
+			if err != nil {
+				fail(errCodeUsage, "Invalid --template: %v", err)
+			}
+		}
+		if *animate {
+			duration, err := time.ParseDuration(*animateDuration)
+			if err != nil || duration <= 0 {
+				fail(errCodeUsage, "Invalid animation duration: %s. Use a duration such as 3s or 1500ms.", *animateDuration)
+			}
+			mapOpts.Animate = duration
+		}
+		crossWeights, err := parseCrossWeights(*weights)
+		if err != nil {
+			fail(errCodeUsage, "Invalid --weight: %v", err)
+		}
+		armLabels, err := parseCrossLabels(*crossLabels)
+		if err != nil {
+			fail(errCodeUsage, "Invalid --cross-label: %v", err)
+		}
+		crossLayout := CrossLayout{Width: *crossWidth, Height: *crossHeight, Labels: armLabels}
+		if err := crossLayout.validate(); err != nil {
+			fail(errCodeUsage, "Invalid cross diagram size: %v", err)
+		}
+		crossOpts := CrossOptions{Theme: crossTheme, Layout: crossLayout, Formula: *crossFormula, Weights: crossWeights, SortArms: *crossSort, Minify: *minify, Gzip: svgz}
+
+		// Ctrl+C cancels any in-flight requests.
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		if *goal < 0 {
+			fail(errCodeUsage, "Invalid goal: %d. Use a daily count of 1 or more, or 0 for none.", *goal)
+		}
+		var compareWindow *dateWindow
+		if *compareTo != "" {
+			window, err := parseDateWindow(*compareTo)
+			if err != nil {
+				fail(errCodeUsage, "Invalid --compare-to: %v", err)
+			}
+			if window.longerThanYear() {
+				fail(errCodeUsage, "Invalid --compare-to: %s spans more than a year; months are compared by name, so use a year or less.", *compareTo)
+			}
+			if _, ok := platforms[platformName].(windowFetcher); !ok {
+				fail(errCodeUsage, "--compare-to is not supported on %s; only GitHub can fetch an earlier window of contributions.", platforms[platformName].Title())
+			}
+			if *input != "" || *serve != "" {
+				fail(errCodeUsage, "--compare-to fetches from the platform, so it cannot be combined with --input or --serve.")
+			}
+			compareWindow = &window
+		}
+		var mapWindow *dateWindow
+		if *dateRange != "" {
+			window, err := parseDateWindow(*dateRange)
+			if err != nil {
+				fail(errCodeUsage, "Invalid --range: %v", err)
+			}
+			if _, ok := platforms[platformName].(windowFetcher); !ok {
+				fail(errCodeUsage, "--range is not supported on %s; only GitHub can fetch a chosen window of contributions.", platforms[platformName].Title())
+			}
+			if *input != "" || *serve != "" {
+				fail(errCodeUsage, "--range fetches from the platform, so it cannot be combined with --input or --serve.")
+			}
+			mapWindow = &window
+			mapOpts.Window = mapWindow
+		}
+		if *concurrency < 1 {
+			fail(errCodeUsage, "Invalid concurrency: %d. Use 1 or more.", *concurrency)
+		}
+		if *check {
+			if err := checkCredentials(ctx, fetchCfg, os.Stdout); err != nil {
+				fail(errorCode(err), "Check failed: %v", err)
+			}
+			return
+		}
+
+		cacheEnabled := !*noCache && *cacheDir != ""
+		if *serve != "" {
+			refreshInterval, err := time.ParseDuration(*refresh)
+			if err != nil || refreshInterval <= 0 {
+				fail(errCodeUsage, "Invalid refresh interval: %s. Use a duration such as 15m or 1h.", *refresh)
+			}
+			// Served maps are kept in memory for --cache-ttl; --no-cache disables that too.
+			serveTTL := cacheTTLValue
+			if *noCache {
+				serveTTL = 0
+			}
+			srv := newServer(fetchCfg, mapOpts, crossOpts, colorScale, serveTTL)
+			if err := srv.serve(ctx, *serve, splitUsers(*user), refreshInterval); err != nil {
+				fail(errCodeOther, "Error serving: %v", err)
+			}
+			return
+		}
+
+		// fetchOne serves a user from the disk cache, or fetches and caches them.
+		// An expired entry with an ETag is revalidated rather than refetched
+		// where the platform supports it, and reused when unchanged.
+		fetchOne := func(ctx context.Context, name string) userFetch {
+			defer progress.userDone()
+			days := time.Now().In(location).Format("2006-01-02")
+			if mapWindow != nil {
+				days = mapWindow.String()
+			}
+			key := cacheKey(platformName, fetchCfg.instance(), name, fetchCfg.Token, days)
+			var stale cacheEntry
+			if cacheEnabled {
+				entry, found, fresh := loadCache(*cacheDir, key, cacheTTLValue)
+				if fresh {
+					return userFetch{Name: name, Weeks: entry.Weeks, CrossData: entry.CrossData, Total: entry.total(), Cached: true}
+				}
+				if found {
+					stale = entry
+				}
+			}
+			var weeks Weeks
+			var userCross CrossData
+			var total int
+			var etag string
+			var err error
+			if mapWindow != nil {
+				weeks, userCross, total, err = fetchCfg.fetchRange(ctx, name, *mapWindow, *concurrency, !*continueOnError)
+			} else {
+				weeks, userCross, total, etag, err = fetchCfg.fetchIfNoneMatch(ctx, name, stale.ETag)
+			}
+			notModified := errors.Is(err, errNotModified)
+			if notModified {
+				verboseLog.Printf("%s: unchanged since %s; reusing the cached contributions", name, stale.FetchedAt.Format(time.RFC3339))
+				weeks, userCross, total, etag, err = stale.Weeks, stale.CrossData, stale.total(), stale.ETag, nil
+			}
+			if err == nil && cacheEnabled {
+				if cacheErr := saveCache(*cacheDir, key, weeks, userCross, total, etag); cacheErr != nil {
+					progress.clear()
+					fmt.Fprintf(os.Stderr, "Warning: could not cache contributions for %s: %v\n", name, cacheErr)
+				}
+			}
+			return userFetch{Name: name, Weeks: weeks, CrossData: userCross, Total: total, Err: err, Cached: notModified}
+		}
+
+		// Fetch every requested user, or read them all from --input; the cross
+		// diagram shows their combined totals.
+		var grids []LabeledWeeks
+		var crossData CrossData
+		var crossByUser []CrossData
+		var fetched []userFetch
+		failedCode := errCodeOther // of the last user that could not be fetched
+		if *input != "" {
+			fetched, err = loadDataFile(*input)
+			if err != nil {
+				fail(errCodeOther, "Error reading input: %v", err)
+			}
+		} else {
+			users := splitUsers(*user)
+			if len(users) == 0 {
+				login, err := fetchGitHubViewerLogin(ctx, *githubURL, *token)
+				if err != nil {
+					fail(errorCode(err), "Could not look up the token's GitHub user: %v", err)
+				}
+				verboseLog.Printf("No --user given; using %s, the login the token belongs to", login)
+				users = []string{login}
+			}
+			// With --range, the workers fetch a user's years instead, one
+			// user at a time, so that --concurrency still bounds the requests.
+			userConcurrency := *concurrency
+			if mapWindow != nil {
+				userConcurrency = 1
+			}
+			progress.start(len(users))
+			fetched = fetchUsers(ctx, users, userConcurrency, !*continueOnError, fetchOne)
+			progress.finish()
+		}
+		for _, result := range fetched {
+			name := result.Name
+			if result.Err != nil {
+				failedCode = errorCode(result.Err)
+				if ctx.Err() != nil || !*continueOnError {
+					fail(failedCode, "%v", result.Err)
+				}
+				fmt.Fprintf(os.Stderr, "%v\n", result.Err)
+				fmt.Fprintf(os.Stderr, "Skipping user %s.\n", name)
+				continue
+			}
+			if result.Cached {
+				fmt.Fprintf(statusOut, "Using cached contributions for %s\n", name)
+			} else {
+				verboseLog.Printf("%s: %d weeks, max daily count %d", name, len(result.Weeks), maxDailyCount(result.Weeks))
+				verboseLog.Printf("%s: commits=%d pull_requests=%d issues=%d code_reviews=%d", name, result.CrossData.Commits, result.CrossData.PullRequests, result.CrossData.Issues, result.CrossData.CodeReviews)
+				if summed := computeStats(result.Weeks).TotalContributions; summed != result.Total {
+					verboseLog.Printf("%s: platform reports %d contributions, daily counts sum to %d", name, result.Total, summed)
+				}
+			}
+			weeks := startWeeksOn(result.Weeks, layout.WeekStart)
+			if *trimEmpty {
+				weeks = trimEmptyWeeks(weeks)
+			}
+			grids = append(grids, LabeledWeeks{Label: name, Weeks: weeks, Total: result.Total, CrossData: result.CrossData})
+			crossByUser = append(crossByUser, result.CrossData)
+			crossData = crossData.add(result.CrossData)
+		}
+		if len(grids) == 0 {
+			fail(failedCode, "No contributions could be fetched for any user.")
+		}
+
+		// With --compare-to, every user whose year was fetched is compared;
+		// the windows are fetched on the same workers as the years.
+		var comparisons []*windowComparison
+		if compareWindow != nil {
+			names := make([]string, len(grids))
+			for i, grid := range grids {
+				names[i] = grid.Label
+			}
+			windows := fetchUsers(ctx, names, *concurrency, !*continueOnError, func(ctx context.Context, name string) userFetch {
+				weeks, windowCross, total, err := fetchCfg.fetchWindow(ctx, name, *compareWindow)
+				return userFetch{Name: name, Weeks: weeks, CrossData: windowCross, Total: total, Err: err}
+			})
+			comparisons = make([]*windowComparison, len(grids))
+			for i, window := range windows {
+				if window.Err != nil {
+					if ctx.Err() != nil || !*continueOnError {
+						fail(errorCode(window.Err), "%v", window.Err)
+					}
+					fmt.Fprintf(os.Stderr, "%v\n", window.Err)
+					fmt.Fprintf(os.Stderr, "Skipping the comparison for %s.\n", window.Name)
+					continue
+				}
+				comparison := compareWindows(grids[i], *compareWindow, window.Weeks, window.CrossData, window.Total)
+				comparisons[i] = &comparison
+			}
+		}
+
+		if *uniformScale {
+			updateUniformColors(grids, theme, colorScale)
+		} else {
+			for _, grid := range grids {
+				updateWeeksColors(grid.Weeks, theme, colorScale)
+			}
+		}
+		mapFilename := *mapOutput
+		if mapFilename == "" {
+			mapFilename = "contributions." + outputExtension
+			if *outputFormat == "sparkline" || *outputFormat == "badge" {
+				mapFilename = "contributions_" + *outputFormat + ".svg"
+			}
+		}
+		crossFilename := *crossOutput
+		if crossFilename == "" {
+			crossFilename = "contributions_cross." + outputExtension
+		}
+
+		var outputFiles []string // for --open
+		switch *outputFormat {
+		case "json":
+			if err := generateJSON(grids, crossByUser, comparisons, platformName, mapFilename); err != nil {
+				fail(errCodeOther, "Error writing JSON: %v", err)
+			}
+			if mapFilename != "-" {
+				fmt.Fprintf(statusOut, "Contribution data written to %s\n", mapFilename)
+				outputFiles = append(outputFiles, mapFilename)
+			}
+		case "sparkline":
+			if err := generateSparklineSVG(grids, *sparklineWidth, *sparklineDots, mapFilename, mapOpts); err != nil {
+				fail(errCodeOther, "Error generating sparkline: %v", err)
+			}
+			if mapFilename != "-" {
+				fmt.Fprintf(statusOut, "Sparkline generated and saved to %s\n", mapFilename)
+				outputFiles = append(outputFiles, mapFilename)
+			}
+		case "badge":
+			if err := generateBadgeSVG(grids, mapFilename, mapOpts); err != nil {
+				fail(errCodeOther, "Error generating badge: %v", err)
+			}
+			if mapFilename != "-" {
+				fmt.Fprintf(statusOut, "Badge generated and saved to %s\n", mapFilename)
+				outputFiles = append(outputFiles, mapFilename)
+			}
+		case "csv":
+			if err := generateCSV(grids, mapFilename); err != nil {
+				fail(errCodeOther, "Error generating CSV: %v", err)
+			}
+			if mapFilename != "-" {
+				fmt.Fprintf(statusOut, "Daily counts written to %s\n", mapFilename)
+				outputFiles = append(outputFiles, mapFilename)
+			}
+		case "pdf":
+			if !*noMap && !layout.Locale.winAnsi() {
+				fmt.Fprintf(statusOut, "Note: the PDF's standard font cannot show the %s month and weekday names; they are drawn in English.\n", *localeOpt)
+			}
+			// One document holds both; it is named after whichever artifact is included first.
+			pdfFilename := mapFilename
+			pdfGrids := grids
+			pdfCross := &crossData
+			if *noMap {
+				pdfFilename = crossFilename
+				pdfGrids = nil
+			}
+			if *noCross {
+				pdfCross = nil
+			}
+			if err := generatePDF(pdfGrids, pdfCross, pdfFilename, mapOpts, crossOpts); err != nil {
+				fail(errCodeOther, "Error generating PDF: %v", err)
+			}
+			if pdfFilename != "-" {
+				fmt.Fprintf(statusOut, "PDF generated and saved to %s\n", pdfFilename)
+				outputFiles = append(outputFiles, pdfFilename)
+			}
+		case "html":
+			// Like pdf, one page holds both.
+			htmlFilename := mapFilename
+			htmlGrids := grids
+			htmlCross := &crossData
+			if *noMap {
+				htmlFilename = crossFilename
+				htmlGrids = nil
+			}
+			if *noCross {
+				htmlCross = nil
+			}
+			if err := generateHTML(htmlGrids, htmlCross, htmlFilename, mapOpts, crossOpts); err != nil {
+				fail(errCodeOther, "Error generating HTML: %v", err)
+			}
+			if htmlFilename != "-" {
+				fmt.Fprintf(statusOut, "HTML page generated and saved to %s\n", htmlFilename)
+				outputFiles = append(outputFiles, htmlFilename)
+			}
+		case "webp":
+			// The rasterizer draws no text, so say so rather than leave the
+			// missing labels to be discovered.
+			fmt.Fprintln(statusOut, "Note: WebP output has no text; month labels, titles and the cross diagram's labels are left out.")
+			if !*noMap {
+				img, err := rasterizeMap(grids, mapOpts)
+				if err == nil {
+					err = writeWebP(mapFilename, img)
+				}
+				if err != nil {
+					fail(errCodeOther, "Error generating contribution map: %v", err)
+				}
+				if mapFilename != "-" {
+					fmt.Fprintf(statusOut, "Contribution map generated and saved to %s\n", mapFilename)
+					outputFiles = append(outputFiles, mapFilename)
+				}
+			}
+			if !*noCross {
+				if err := writeWebP(crossFilename, rasterizeCross(crossData, crossOpts)); err != nil {
+					fail(errCodeOther, "Error generating cross diagram: %v", err)
+				}
+				if crossFilename != "-" {
+					fmt.Fprintf(statusOut, "Cross diagram generated and saved to %s\n", crossFilename)
+					outputFiles = append(outputFiles, crossFilename)
+				}
+			}
+		default:
+			if *combined {
+				if err := generateCombinedSVG(grids, crossData, mapFilename, mapOpts, crossOpts, *combinedLayout == "stacked"); err != nil {
+					fail(errCodeOther, "Error generating combined SVG: %v", err)
+				}
+				if mapFilename != "-" {
+					fmt.Fprintf(statusOut, "Contribution map and cross diagram generated and saved to %s\n", mapFilename)
+					outputFiles = append(outputFiles, mapFilename)
+				}
+				break
+			}
+			if !*noMap {
+				if *granularity != granularityDaily {
+					err = generateBarChartSVG(grids, *granularity, mapFilename, mapOpts)
+				} else if *view == viewCalendarMonths {
+					err = generateCalendarSVG(grids, mapFilename, mapOpts)
+				} else if len(grids) == 1 {
+					err = generateSVG(grids[0], mapFilename, mapOpts)
+				} else if mapOpts.Template != nil {
+					err = errors.New("--template renders a single user's map")
+				} else {
+					err = generateMultiSVG(grids, mapFilename, mapOpts)
+				}
+				if err != nil {
+					fail(errCodeOther, "Error generating contribution map: %v", err)
+				}
+				if mapFilename != "-" {
+					fmt.Fprintf(statusOut, "Contribution map generated and saved to %s\n", mapFilename)
+					outputFiles = append(outputFiles, mapFilename)
+				}
+			}
+			if !*noCross {
+				if err := generateCrossSVG(crossData, crossFilename, crossOpts); err != nil {
+					fail(errCodeOther, "Error generating cross diagram: %v", err)
+				}
+				if crossFilename != "-" {
+					fmt.Fprintf(statusOut, "Cross diagram generated and saved to %s\n", crossFilename)
+					outputFiles = append(outputFiles, crossFilename)
+				}
+			}
+		}
+
+		for _, grid := range grids {
+			stats := computeStats(grid.Weeks)
+			if *goal > 0 {
+				stats.Goal, stats.GoalDays = *goal, goalDays(grid.Weeks, *goal)
+			}
+			printStats(statusOut, grid.Label, stats)
+		}
+		for i, comparison := range comparisons {
+			if comparison != nil {
+				printComparison(statusOut, grids[i].Label, *comparison)
+			}
+		}
+
+		if *openOutput {
+			if os.Getenv("CI") != "" {
+				verboseLog.Printf("Not opening the output under CI")
+				return
+			}
+			for _, filename := range outputFiles {
+				if filename == "-" {
+					continue
+				}
+				if err := openInViewer(filename); err != nil {
+					fmt.Fprintf(os.Stderr, "Warning: could not open %s: %v\n", filename, err)
+				}
+			}
+		}
+	}
+
+	args, err := withConfigArgs(os.Args)
+	if err != nil {
+		fail(errCodeUsage, "Invalid configuration file: %v", err)
+	}
+	app.Run(args)
 }
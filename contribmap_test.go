@@ -0,0 +1,750 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files under testdata")
+
+// checkGolden compares got with testdata/name, or with -update rewrites it.
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run go test -update to create it)", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("output differs from %s (run go test -update to accept it)\ngot:\n%s", path, got)
+	}
+}
+
+// testWeeks lays out days of counts starting on start, which is a Sunday for
+// a grid like GitHub's, with count giving each day's count by its index.
+func testWeeks(start string, days int, count func(i int) int) Weeks {
+	from, err := time.Parse("2006-01-02", start)
+	if err != nil {
+		panic(err)
+	}
+	counts := make(map[string]int)
+	for i := 0; i < days; i++ {
+		counts[from.AddDate(0, 0, i).Format("2006-01-02")] = count(i)
+	}
+	return buildWeeks(counts, from, from.AddDate(0, 0, days-1))
+}
+
+// testMapOptions are the options of a plain contribmap run.
+func testMapOptions() MapOptions {
+	return MapOptions{Theme: defaultTheme(false), Layout: MapLayout{CellSize: defaultCellSize, CellMargin: defaultCellMargin}}
+}
+
+// testCrossOptions are the options of a plain contribmap run.
+func testCrossOptions() CrossOptions {
+	return CrossOptions{Theme: defaultTheme(false), Layout: CrossLayout{Width: 300, Height: 300, Labels: defaultCrossLabels}, Formula: crossFormulaAxes}
+}
+
+func TestFetchGitHubContributions(t *testing.T) {
+	var variables map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "bearer secret" {
+			t.Errorf("Authorization = %q, want bearer secret", got)
+		}
+		var body struct {
+			Variables map[string]interface{} `json:"variables"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decoding the request: %v", err)
+		}
+		variables = body.Variables
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", `W/"1"`)
+		fmt.Fprint(w, `{"data": {"user": {"contributionsCollection": {
+			"totalCommitContributions": 5,
+			"totalPullRequestContributions": 2,
+			"totalIssueContributions": 1,
+			"totalPullRequestReviewContributions": 3,
+			"contributionCalendar": {"totalContributions": 11, "weeks": [
+				{"contributionDays": [{"date": "2025-01-05", "contributionCount": 4}, {"date": "2025-01-06", "contributionCount": 0}]},
+				{"contributionDays": [{"date": "2025-01-12", "contributionCount": 7}]}
+			]}
+		}}}}`)
+	}))
+	defer srv.Close()
+
+	weeks, crossData, total, etag, err := fetchGitHubContributions(context.Background(), srv.URL, "octo", "secret", nil, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if variables["login"] != "octo" {
+		t.Errorf("login = %v, want octo", variables["login"])
+	}
+	if _, ok := variables["from"]; ok {
+		t.Errorf("from is set without a window")
+	}
+	wantWeeks := Weeks{
+		{{Date: "2025-01-05", Count: 4}, {Date: "2025-01-06", Count: 0}},
+		{{Date: "2025-01-12", Count: 7}},
+	}
+	if !reflect.DeepEqual(weeks, wantWeeks) {
+		t.Errorf("weeks = %v, want %v", weeks, wantWeeks)
+	}
+	if want := (CrossData{Commits: 5, PullRequests: 2, Issues: 1, CodeReviews: 3}); crossData != want {
+		t.Errorf("crossData = %+v, want %+v", crossData, want)
+	}
+	if total != 11 {
+		t.Errorf("total = %d, want 11", total)
+	}
+	if etag != `W/"1"` {
+		t.Errorf("etag = %q, want W/\"1\"", etag)
+	}
+}
+
+func TestFetchGiteaContributions(t *testing.T) {
+	now := time.Now().UTC()
+	today := now.Format("2006-01-02")
+	yesterday := now.AddDate(0, 0, -1).Format("2006-01-02")
+	events := []map[string]string{
+		{"type": "pushevent", "created_at": today + "T00:10:00Z"},
+		{"type": "pullrequestevent", "created_at": today + "T00:05:00Z"},
+		{"type": "issuecommentevent", "created_at": yesterday + "T12:00:00Z"},
+		{"type": "pullrequestreviewevent", "created_at": yesterday + "T11:00:00Z"},
+		{"type": "createevent", "created_at": yesterday + "T10:00:00Z"},
+		{"type": "pushevent", "created_at": now.AddDate(-2, 0, 0).Format(time.RFC3339)},
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/users/bob/events" {
+			t.Errorf("path = %s, want /api/v1/users/bob/events", r.URL.Path)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("page") != "1" {
+			fmt.Fprint(w, "[]")
+			return
+		}
+		json.NewEncoder(w).Encode(events)
+	}))
+	defer srv.Close()
+
+	weeks, crossData, err := fetchGiteaContributions(context.Background(), "bob", srv.URL, giteaEventsPath, "", time.UTC, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(weeks) != trailingWeeks {
+		t.Errorf("got %d weeks, want %d", len(weeks), trailingWeeks)
+	}
+	counts := make(map[string]int)
+	for _, week := range weeks {
+		if len(week) != 7 {
+			t.Errorf("week of %d days, want 7", len(week))
+		}
+		for _, day := range week {
+			counts[day.Date] += day.Count
+		}
+	}
+	if counts[today] != 2 || counts[yesterday] != 3 {
+		t.Errorf("today %d and yesterday %d contributions, want 2 and 3", counts[today], counts[yesterday])
+	}
+	if sum := computeStats(weeks).TotalContributions; sum != 5 {
+		t.Errorf("total = %d, want 5 (the event before the window is dropped)", sum)
+	}
+	if want := (CrossData{Commits: 1, PullRequests: 1, Issues: 1, CodeReviews: 1}); crossData != want {
+		t.Errorf("crossData = %+v, want %+v", crossData, want)
+	}
+}
+
+func TestGenerateSVGGolden(t *testing.T) {
+	weeks := testWeeks("2024-01-07", 364, func(i int) int { return (i * 7) % 13 })
+	opts := testMapOptions()
+	updateWeeksColors(weeks, opts.Theme, ColorScale{Kind: scaleLinear})
+	path := filepath.Join(t.TempDir(), "map.svg")
+	if err := generateSVG(LabeledWeeks{Label: "octo", Weeks: weeks, Total: computeStats(weeks).TotalContributions}, path, opts); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "map.svg", got)
+}
+
+func TestGenerateCrossSVGGolden(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cross.svg")
+	if err := generateCrossSVG(CrossData{Commits: 120, PullRequests: 30, Issues: 12, CodeReviews: 45}, path, testCrossOptions()); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "cross.svg", got)
+}
+
+// giteaPagesServer serves events as a Gitea feed, giteaPageLimit per page,
+// and records the pages requested.
+func giteaPagesServer(t *testing.T, events []map[string]string, totalCount bool) (*httptest.Server, *[]int) {
+	var pages []int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if limit := r.URL.Query().Get("limit"); limit != strconv.Itoa(giteaPageLimit) {
+			t.Errorf("limit = %s, want %d", limit, giteaPageLimit)
+		}
+		pages = append(pages, page)
+		start := min((page-1)*giteaPageLimit, len(events))
+		end := min(start+giteaPageLimit, len(events))
+		w.Header().Set("Content-Type", "application/json")
+		if totalCount {
+			w.Header().Set("X-Total-Count", strconv.Itoa(len(events)))
+		}
+		json.NewEncoder(w).Encode(events[start:end])
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &pages
+}
+
+func TestFetchGiteaContributionsPages(t *testing.T) {
+	// 120 events over the last 40 days, three on each, newest first.
+	now := time.Now().UTC()
+	var events []map[string]string
+	for i := 0; i < 120; i++ {
+		created := now.AddDate(0, 0, -i/3).Add(-time.Duration(i%3) * time.Minute)
+		events = append(events, map[string]string{"type": "pushevent", "created_at": created.Format(time.RFC3339)})
+	}
+
+	for _, tc := range []struct {
+		name       string
+		totalCount bool
+		wantPages  []int
+	}{
+		{"until an empty page", false, []int{1, 2, 3, 4}},
+		{"until X-Total-Count", true, []int{1, 2, 3}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			srv, pages := giteaPagesServer(t, events, tc.totalCount)
+			weeks, crossData, err := fetchGiteaContributions(context.Background(), "bob", srv.URL, giteaEventsPath, "", time.UTC, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(*pages, tc.wantPages) {
+				t.Errorf("requested pages %v, want %v", *pages, tc.wantPages)
+			}
+			if sum := computeStats(weeks).TotalContributions; sum != 120 {
+				t.Errorf("total = %d, want 120 from every page", sum)
+			}
+			if crossData.Commits != 120 {
+				t.Errorf("commits = %d, want 120", crossData.Commits)
+			}
+			for _, week := range weeks {
+				for _, day := range week {
+					if day.Count != 0 && day.Count != 3 {
+						t.Errorf("%s has %d contributions, want 3", day.Date, day.Count)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestFetchGiteaContributionsStopsAtWindowStart(t *testing.T) {
+	// Page 2 reaches back past the trailing year, so page 3 is not needed.
+	now := time.Now().UTC()
+	var events []map[string]string
+	for i := 0; i < 3*giteaPageLimit; i++ {
+		created := now.AddDate(0, 0, -i*5)
+		events = append(events, map[string]string{"type": "pushevent", "created_at": created.Format(time.RFC3339)})
+	}
+	srv, pages := giteaPagesServer(t, events, false)
+	weeks, _, err := fetchGiteaContributions(context.Background(), "bob", srv.URL, giteaEventsPath, "", time.UTC, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{1, 2}; !reflect.DeepEqual(*pages, want) {
+		t.Errorf("requested pages %v, want %v", *pages, want)
+	}
+	_, windowStart := trailingYearWindowAt(now)
+	want := 0
+	for _, event := range events {
+		created, _ := time.Parse(time.RFC3339, event["created_at"])
+		if !created.Before(windowStart) {
+			want++
+		}
+	}
+	if sum := computeStats(weeks).TotalContributions; sum != want {
+		t.Errorf("total = %d, want the %d events inside the window", sum, want)
+	}
+}
+
+func TestGetColor(t *testing.T) {
+	theme := defaultTheme(false)
+	for _, tc := range []struct {
+		maxCount int
+		count    int
+		bucket   int // -1 for the zero color
+	}{
+		{1, 0, -1},
+		{1, 1, 4},
+		{4, 1, 1},
+		{4, 2, 2},
+		{4, 3, 3},
+		{4, 4, 4},
+		{5, 1, 0},
+		{5, 2, 1},
+		{5, 3, 2},
+		{5, 4, 3},
+		{5, 5, 4},
+		{100, 1, 0},
+		{100, 20, 0},
+		{100, 21, 1},
+		{100, 50, 2},
+		{100, 80, 3},
+		{100, 81, 4},
+		{100, 100, 4},
+		{100, 250, 4}, // above a pinned --max-count
+	} {
+		want := theme.Zero
+		if tc.bucket >= 0 {
+			want = theme.Buckets[tc.bucket]
+		}
+		if got := getColor(tc.count, tc.maxCount, theme); got != want {
+			t.Errorf("getColor(%d, %d) = %s, want bucket %d (%s)", tc.count, tc.maxCount, got, tc.bucket, want)
+		}
+	}
+}
+
+func TestSkewedDistributionColors(t *testing.T) {
+	// Mostly single contributions with a few busy days.
+	counts := []int{100, 40, 12}
+	for i := 0; i < 60; i++ {
+		counts = append(counts, 1+i%4)
+	}
+	weeks := testWeeks("2024-01-07", len(counts), func(i int) int { return counts[i] })
+	theme := defaultTheme(false)
+	bucketsUsed := func(kind string) map[string]bool {
+		updateWeeksColors(weeks, theme, ColorScale{Kind: kind})
+		used := make(map[string]bool)
+		for _, week := range weeks {
+			for _, day := range week {
+				if day.Date == "2024-01-07" && day.Color != theme.brightestBucket() {
+					t.Errorf("%s: the busiest day is %s, want the brightest bucket", kind, day.Color)
+				}
+				if day.Count > 0 {
+					used[day.Color] = true
+				}
+			}
+		}
+		return used
+	}
+	if used := bucketsUsed(scaleLinear); len(used) != 3 {
+		t.Errorf("linear scale used %d buckets, want 3: the skew crowds every small count into the first", len(used))
+	}
+	if used := bucketsUsed(scaleQuantile); len(used) != len(theme.Buckets) {
+		t.Errorf("quantile scale used %d buckets, want all %d", len(used), len(theme.Buckets))
+	}
+}
+
+func TestZeroContributionUser(t *testing.T) {
+	opts := testMapOptions()
+	weeks := testWeeks("2024-01-07", 364, func(int) int { return 0 })
+	updateWeeksColors(weeks, opts.Theme, ColorScale{Kind: scaleLinear})
+	svg, err := renderSVG(LabeledWeeks{Label: "idle", Weeks: weeks}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := bytes.Count(svg, []byte(`<use xlink:href="#zero-cell"`)); n != 364 {
+		t.Errorf("%d zero cells, want 364", n)
+	}
+	if !bytes.Contains(svg, []byte(`aria-label="0 contributions from 2024-01-07 to 2025-01-04"`)) {
+		t.Errorf("summary missing:\n%s", svg)
+	}
+
+	cross := renderCrossSVG(CrossData{}, testCrossOptions())
+	if bytes.Contains(cross, []byte("NaN")) {
+		t.Errorf("cross diagram of no contributions has NaN:\n%s", cross)
+	}
+}
+
+func TestEmptyWeeksPlaceholder(t *testing.T) {
+	for _, weeks := range []Weeks{nil, {}} {
+		svg, err := renderSVG(LabeledWeeks{Label: "none", Weeks: weeks}, testMapOptions())
+		if err != nil {
+			t.Fatal(err)
+		}
+		width, _, err := mapGridSize(0, testMapOptions())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if width < 100 {
+			t.Errorf("empty map is %d pixels wide", width)
+		}
+		if !bytes.Contains(svg, []byte(fmt.Sprintf(`<svg width="%d"`, width))) || !bytes.Contains(svg, []byte(">No contributions</text>")) {
+			t.Errorf("no placeholder in:\n%s", svg)
+		}
+	}
+}
+
+func TestCrossDotPosition(t *testing.T) {
+	// In the default 300x300 layout the center is (150, 150) and the arms
+	// end at 50 and 250.
+	for _, tc := range []struct {
+		name    string
+		formula string
+		data    CrossData
+		x, y    float64
+	}{
+		{"axes, nothing", crossFormulaAxes, CrossData{}, 150, 150},
+		{"axes, balanced", crossFormulaAxes, CrossData{Commits: 10, PullRequests: 10, Issues: 10, CodeReviews: 10}, 150, 150},
+		{"axes, commits only", crossFormulaAxes, CrossData{Commits: 100}, 50, 150},
+		{"axes, commits and one pull request", crossFormulaAxes, CrossData{Commits: 100, PullRequests: 1}, 50, 250},
+		{"axes, three to one", crossFormulaAxes, CrossData{Commits: 30, Issues: 10, CodeReviews: 10, PullRequests: 30}, 100, 200},
+		{"centroid, nothing", crossFormulaCentroid, CrossData{}, 150, 150},
+		{"centroid, balanced", crossFormulaCentroid, CrossData{Commits: 10, PullRequests: 10, Issues: 10, CodeReviews: 10}, 150, 150},
+		{"centroid, commits only", crossFormulaCentroid, CrossData{Commits: 100}, 50, 150},
+		{"centroid, commits and one pull request", crossFormulaCentroid, CrossData{Commits: 99, PullRequests: 1}, 51, 151},
+		{"centroid, reviews and issues", crossFormulaCentroid, CrossData{CodeReviews: 3, Issues: 1}, 175, 75},
+	} {
+		opts := testCrossOptions()
+		opts.Formula = tc.formula
+		x, y := crossDotPosition(crossArms(tc.data, opts), tc.formula, opts.Layout)
+		if math.Abs(x-tc.x) > 1e-9 || math.Abs(y-tc.y) > 1e-9 {
+			t.Errorf("%s: dot at (%g, %g), want (%g, %g)", tc.name, x, y, tc.x, tc.y)
+		}
+	}
+}
+
+func TestFetchGiteaContributionsTimezone(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := time.Now().UTC().AddDate(0, 0, -10)
+	date := day.Format("2006-01-02")
+	nextDate := day.AddDate(0, 0, 1).Format("2006-01-02")
+	events := []map[string]string{
+		{"type": "pushevent", "created_at": nextDate + "T02:30:00Z"}, // the evening before in New York
+		{"type": "pushevent", "created_at": date + "T23:30:00Z"},     // 18:30 or 19:30 in New York
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Total-Count", strconv.Itoa(len(events)))
+		json.NewEncoder(w).Encode(events)
+	}))
+	defer srv.Close()
+
+	for _, tc := range []struct {
+		loc  *time.Location
+		want map[string]int
+	}{
+		{time.UTC, map[string]int{date: 1, nextDate: 1}},
+		{newYork, map[string]int{date: 2, nextDate: 0}},
+	} {
+		weeks, _, err := fetchGiteaContributions(context.Background(), "bob", srv.URL, giteaEventsPath, "", tc.loc, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts := make(map[string]int)
+		for _, week := range weeks {
+			for _, day := range week {
+				counts[day.Date] = day.Count
+			}
+		}
+		for date, want := range tc.want {
+			if counts[date] != want {
+				t.Errorf("%s: %s has %d contributions, want %d", tc.loc, date, counts[date], want)
+			}
+		}
+	}
+}
+
+func TestTrailingYearWindowTimezone(t *testing.T) {
+	// 23:30 UTC on Saturday 2025-03-01 is still the afternoon in New York but
+	// already Sunday in Tokyo, which starts a new week.
+	now := time.Date(2025, 3, 1, 23, 30, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		zone        string
+		today, week string
+	}{
+		{"America/New_York", "2025-03-01", "2025-02-23"},
+		{"UTC", "2025-03-01", "2025-02-23"},
+		{"Asia/Tokyo", "2025-03-02", "2025-03-02"},
+	} {
+		loc, err := time.LoadLocation(tc.zone)
+		if err != nil {
+			t.Fatal(err)
+		}
+		today, windowStart := trailingYearWindowAt(now.In(loc))
+		lastWeek := windowStart.AddDate(0, 0, 7*(trailingWeeks-1))
+		if got := today.Format("2006-01-02"); got != tc.today {
+			t.Errorf("%s: today is %s, want %s", tc.zone, got, tc.today)
+		}
+		if got := lastWeek.Format("2006-01-02"); got != tc.week {
+			t.Errorf("%s: last week starts %s, want %s", tc.zone, got, tc.week)
+		}
+		if windowStart.Location() != loc || windowStart.Hour() != 0 {
+			t.Errorf("%s: window starts at %s, want midnight in the zone", tc.zone, windowStart)
+		}
+	}
+}
+
+func TestTrailingYearWeeks(t *testing.T) {
+	// Every weekday of today gives GitHub's 53 columns, the last holding today.
+	for day := 2; day <= 8; day++ {
+		now := time.Date(2025, 3, day, 15, 0, 0, 0, time.UTC)
+		today, windowStart := trailingYearWindowAt(now)
+		weeks := buildWeeks(map[string]int{}, windowStart, today)
+		if len(weeks) != trailingWeeks {
+			t.Errorf("%s: %d weeks, want %d", now.Weekday(), len(weeks), trailingWeeks)
+			continue
+		}
+		if windowStart.Weekday() != time.Sunday {
+			t.Errorf("%s: the window starts on %s, want Sunday", now.Weekday(), windowStart.Weekday())
+		}
+		last := weeks[len(weeks)-1]
+		if got := last[now.Weekday()].Date; got != now.Format("2006-01-02") {
+			t.Errorf("%s: the last column has %q in today's row, want %s", now.Weekday(), got, now.Format("2006-01-02"))
+		}
+		for i, week := range weeks {
+			if len(week) != 7 {
+				t.Errorf("%s: week %d has %d days, want 7", now.Weekday(), i, len(week))
+			}
+		}
+	}
+}
+
+func TestGenerateSVGDeterministic(t *testing.T) {
+	// Build the same grid twice from maps filled in opposite orders, and
+	// render it with the options that collect things along the way.
+	render := func(reverse bool) []byte {
+		counts := make(map[string]int)
+		from := time.Date(2024, 1, 7, 0, 0, 0, 0, time.UTC)
+		for i := 0; i < 364; i++ {
+			day := i
+			if reverse {
+				day = 363 - i
+			}
+			counts[from.AddDate(0, 0, day).Format("2006-01-02")] = (day * day) % 11
+		}
+		weeks := buildWeeks(counts, from, from.AddDate(0, 0, 363))
+		opts := testMapOptions()
+		opts.Layout.Title, opts.Layout.WeekdayLabels, opts.Layout.Rounded = true, true, true
+		opts.HighlightStreak, opts.Goal, opts.CellLabels, opts.ShadeWeekends, opts.RichTooltips = true, 8, true, true, true
+		light := defaultTheme(true)
+		opts.AutoLight = &light
+		opts.DayLink = func(user, date string) string { return "https://example.com/" + user + "?date=" + date }
+		updateWeeksColors(weeks, opts.Theme, ColorScale{Kind: scaleQuantile})
+		svg, err := renderSVG(LabeledWeeks{Label: "octo", Weeks: weeks, Total: computeStats(weeks).TotalContributions}, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return svg
+	}
+	first := render(false)
+	for i := 0; i < 3; i++ {
+		if again := render(i%2 == 0); !bytes.Equal(again, first) {
+			t.Fatalf("render %d differs from the first", i+2)
+		}
+	}
+	checkGolden(t, "map_features.svg", first)
+}
+
+func TestStartWeeksOnYearBoundary(t *testing.T) {
+	// December 2024 to January 2025; 2024-12-01 is a Sunday and 2025-01-01 a
+	// Wednesday.
+	for _, tc := range []struct {
+		start       time.Weekday
+		padding     int // undated days before 2024-12-01
+		janColumn   int
+		weekdayRows [7]string
+	}{
+		{time.Sunday, 0, 4, [7]string{"", "Mon", "", "Wed", "", "Fri", ""}},
+		{time.Monday, 6, 5, [7]string{"Mon", "", "Wed", "", "Fri", "", ""}},
+	} {
+		weeks := startWeeksOn(testWeeks("2024-12-01", 62, func(i int) int { return i % 3 }), tc.start)
+		layout := MapLayout{CellSize: defaultCellSize, CellMargin: defaultCellMargin, WeekStart: tc.start}
+		var dates []string
+		for i, week := range weeks {
+			if len(week) != 7 {
+				t.Errorf("%s: week %d has %d days, want 7", tc.start, i, len(week))
+			}
+			for row, day := range week {
+				if day.Date == "" {
+					if i == 0 && row >= tc.padding {
+						t.Errorf("%s: undated day in row %d of the first week", tc.start, row)
+					}
+					continue
+				}
+				date, _ := time.Parse("2006-01-02", day.Date)
+				if layout.weekdayRow(date.Weekday()) != row {
+					t.Errorf("%s: %s (%s) is in row %d", tc.start, day.Date, date.Weekday(), row)
+				}
+				dates = append(dates, day.Date)
+			}
+		}
+		if len(dates) != 62 || dates[0] != "2024-12-01" || dates[61] != "2025-01-31" {
+			t.Errorf("%s: %d days from %v, want the 62 from 2024-12-01 to 2025-01-31", tc.start, len(dates), dates[:1])
+		}
+
+		labels := monthLabels(weeks, layout)
+		janX, _ := layout.cellOrigin(tc.janColumn, 0)
+		found := false
+		for _, label := range labels {
+			if label.Label == "Jan" {
+				found = true
+				if label.X != janX {
+					t.Errorf("%s: Jan label at x=%d, want %d over column %d", tc.start, label.X, janX, tc.janColumn)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("%s: no Jan label in %v", tc.start, labels)
+		}
+		if got := layout.weekdayLabels(); got != tc.weekdayRows {
+			t.Errorf("%s: weekday labels %q, want %q", tc.start, got, tc.weekdayRows)
+		}
+	}
+}
+
+func TestLeapYearFebruary(t *testing.T) {
+	for _, tc := range []struct {
+		year         int
+		februaryDays int
+	}{
+		{2024, 29},
+		{2023, 28},
+	} {
+		start := time.Date(tc.year, 1, 1, 0, 0, 0, 0, time.UTC)
+		days := int(time.Date(tc.year, 5, 1, 0, 0, 0, 0, time.UTC).Sub(start).Hours() / 24)
+		weeks := startWeeksOn(testWeeks(start.Format("2006-01-02"), days, func(i int) int { return i + 1 }), time.Sunday)
+		layout := MapLayout{CellSize: defaultCellSize, CellMargin: defaultCellMargin}
+
+		february := 0
+		for _, week := range weeks {
+			for row, day := range week {
+				if day.Date == "" {
+					continue
+				}
+				date, _ := time.Parse("2006-01-02", day.Date)
+				if int(date.Weekday()) != row {
+					t.Errorf("%d: %s is in row %d", tc.year, day.Date, row)
+				}
+				if day.Count != date.YearDay() {
+					t.Errorf("%d: %s has count %d, want %d", tc.year, day.Date, day.Count, date.YearDay())
+				}
+				if date.Month() == time.February {
+					february++
+				}
+			}
+		}
+		if february != tc.februaryDays {
+			t.Errorf("%d: %d February days, want %d", tc.year, february, tc.februaryDays)
+		}
+
+		// Also with the 1st of the later months missing, as in a gappy
+		// --input file; their labels follow the first day that is there.
+		gappy := make(Weeks, len(weeks))
+		for i, week := range weeks {
+			gappy[i] = slices.Clone(week)
+			for j, day := range week {
+				if strings.HasSuffix(day.Date, "-01") && !strings.HasSuffix(day.Date, "-01-01") {
+					gappy[i][j] = ContributionDay{}
+				}
+			}
+		}
+		for _, grid := range []Weeks{weeks, gappy} {
+			var got []string
+			for _, label := range monthLabels(grid, layout) {
+				got = append(got, label.Label)
+			}
+			if want := []string{"Jan", "Feb", "Mar", "Apr"}; !slices.Equal(got, want) {
+				t.Errorf("%d: month labels %v, want %v", tc.year, got, want)
+			}
+		}
+	}
+}
+
+func TestTwoYearStrip(t *testing.T) {
+	weeks := testWeeks("2023-01-01", 7*105, func(i int) int { return (i * 5) % 9 })
+	opts := testMapOptions()
+	updateWeeksColors(weeks, opts.Theme, ColorScale{Kind: scaleLinear})
+	grid := LabeledWeeks{Label: "octo", Weeks: weeks, Total: computeStats(weeks).TotalContributions}
+
+	svg, err := renderSVG(grid, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	width, height, err := mapGridSize(len(weeks), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pitch := defaultCellSize + defaultCellMargin
+	if want := 105*pitch + defaultCellMargin; width != want {
+		t.Errorf("width %d, want %d for 105 columns", width, want)
+	}
+	if !bytes.HasPrefix(svg, []byte(fmt.Sprintf(`<svg width="%d" height="%d"`, width, height))) {
+		t.Errorf("SVG does not start with its size:\n%.200s", svg)
+	}
+	if n := bytes.Count(svg, []byte("data-date=")); n != 7*105 {
+		t.Errorf("%d dated cells, want %d", n, 7*105)
+	}
+	checkGolden(t, "map_two_years.svg", svg)
+
+	// Januaries are labeled with their years, and no label runs into the
+	// one before it, in one strip or wrapped into two rows. The second row
+	// starts in January 2024, so it repeats that label.
+	for _, tc := range []struct {
+		wrap  int
+		years []string
+	}{
+		{1, []string{"2023", "2024", "2025"}},
+		{2, []string{"2023", "2024", "2024", "2025"}},
+	} {
+		wrap := tc.wrap
+		layout := opts.Layout
+		layout.Wrap = wrap
+		layout = layout.forWeeks(len(weeks))
+		labels := monthLabels(weeks, layout)
+		var years []string
+		for i, label := range labels {
+			if label.Label == "2023" || label.Label == "2024" || label.Label == "2025" {
+				years = append(years, label.Label)
+			}
+			if label.Label == "Jan" {
+				t.Errorf("wrap %d: January labeled Jan on a two-year map", wrap)
+			}
+			if i > 0 && labels[i-1].Y == label.Y && label.X < labels[i-1].X+layout.labelWidth(labels[i-1].Label) {
+				t.Errorf("wrap %d: %s at x=%d runs into %s at x=%d", wrap, label.Label, label.X, labels[i-1].Label, labels[i-1].X)
+			}
+		}
+		if !slices.Equal(years, tc.years) {
+			t.Errorf("wrap %d: year labels %v, want %v", wrap, years, tc.years)
+		}
+	}
+
+	// A strip too wide for an SVG is an error rather than an overflow.
+	huge := opts
+	huge.Layout.CellSize = 1 << 20
+	if _, _, err := mapGridSize(len(weeks), huge); err == nil {
+		t.Errorf("no error for a %d pixel cell", huge.Layout.CellSize)
+	}
+	if _, err := renderSVG(grid, huge); err == nil {
+		t.Errorf("rendered a map with %d pixel cells", huge.Layout.CellSize)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+)
+
+// =============================================================================
+// Error Reporting (--json-errors)
+// =============================================================================
+
+// Error codes reported with --json-errors. They are part of the command-line
+// interface: add new ones, but do not rename them.
+const (
+	errCodeUsage     = "usage"      // invalid options or option combinations
+	errCodeAuth      = "auth"       // the token is missing, invalid or lacks access
+	errCodeNotFound  = "not_found"  // the user or instance does not exist
+	errCodeRateLimit = "rate_limit" // the platform's rate limit is exhausted
+	errCodeNetwork   = "network"    // the platform could not be reached
+	errCodeOther     = "error"      // anything else, such as a failed write
+)
+
+// exitStatuses are the exit statuses fail uses for each error code, so that
+// scripts can tell failures apart without --json-errors; any other code exits
+// with 1. Like the codes, they are part of the command-line interface.
+var exitStatuses = map[string]int{
+	errCodeUsage:     2, // as for options the command line parser rejects
+	errCodeAuth:      3,
+	errCodeNotFound:  4,
+	errCodeRateLimit: 5,
+	errCodeNetwork:   6,
+}
+
+// exitStatusHelp documents exitStatuses in --help.
+const exitStatusHelp = "Exit status: 0 on success, 2 for invalid options, 3 for a missing or rejected token, 4 for an unknown user or instance, 5 when rate limited, 6 for network errors and 1 for anything else."
+
+// exitStatus returns the exit status for an error code.
+func exitStatus(code string) int {
+	if status, ok := exitStatuses[code]; ok {
+		return status
+	}
+	return 1
+}
+
+// jsonErrors makes fail print a JSON object on stdout instead of text on
+// stderr; set by --json-errors.
+var jsonErrors bool
+
+// codedError tags an error with one of the error codes above.
+type codedError struct {
+	code string
+	err  error
+}
+
+func (e *codedError) Error() string { return e.err.Error() }
+
+func (e *codedError) Unwrap() error { return e.err }
+
+// withCode tags err with code.
+func withCode(code string, err error) error {
+	return &codedError{code: code, err: err}
+}
+
+// statusCode returns the error code for a failed HTTP response status.
+func statusCode(status int) string {
+	switch status {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return errCodeAuth
+	case http.StatusNotFound:
+		return errCodeNotFound
+	case http.StatusTooManyRequests:
+		return errCodeRateLimit
+	}
+	return errCodeOther
+}
+
+// errorCode classifies err: by its tag, the HTTP status of a Bitbucket error,
+// or as a network error when the request itself failed.
+func errorCode(err error) string {
+	var coded *codedError
+	if errors.As(err, &coded) {
+		return coded.code
+	}
+	var statusErr *bitbucketStatusError
+	if errors.As(err, &statusErr) {
+		return statusCode(statusErr.StatusCode)
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return errCodeNetwork
+	}
+	return errCodeOther
+}
+
+// fail reports a fatal error and exits with the status for code. The message
+// is written to stderr, or with --json-errors as {"error": "...", "code":
+// "..."} to stdout.
+func fail(code, format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	if jsonErrors {
+		json.NewEncoder(os.Stdout).Encode(struct {
+			Error string `json:"error"`
+			Code  string `json:"code"`
+		}{message, code})
+	} else {
+		fmt.Fprintln(os.Stderr, message)
+	}
+	os.Exit(exitStatus(code))
+}
@@ -0,0 +1,670 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// =============================================================================
+// PDF Export
+// =============================================================================
+
+// generatePDF writes a PDF with one page per contribution map followed by a
+// page with the cross diagram; a nil crossData leaves that page out. Pages are sized like the corresponding SVGs and
+// drawn with vector operators and the standard Helvetica font, so no fonts or
+// external libraries are needed.
+func generatePDF(grids []LabeledWeeks, crossData *CrossData, outputFilename string, opts MapOptions, crossOpts CrossOptions) error {
+	var pages []pdfPage
+	for _, grid := range grids {
+		width, height, err := mapGridSize(len(grid.Weeks), opts)
+		if err != nil {
+			return err
+		}
+		page := newPDFPage(width, height)
+		if !opts.Theme.Transparent {
+			page.fillRect(0, 0, float64(width), float64(height), opts.Theme.Background)
+		}
+		writeMapGridPDF(page, grid.Weeks, grid.Total, opts)
+		pages = append(pages, *page)
+	}
+
+	if crossData != nil {
+		page := newPDFPage(crossOpts.Layout.Width, crossOpts.Layout.Height)
+		writeCrossPDF(page, *crossData, crossOpts)
+		pages = append(pages, *page)
+	}
+
+	data := encodePDF(pages)
+	if err := checkPDF(data); err != nil {
+		return fmt.Errorf("generated PDF is malformed: %w", err)
+	}
+	return writeOutputFile(outputFilename, data)
+}
+
+// writeMapGridPDF draws the title, month labels, weekday labels and cells of a
+// map. Labels in a locale the standard font cannot show are drawn in English.
+func writeMapGridPDF(page *pdfPage, weeks Weeks, total int, opts MapOptions) {
+	layout := opts.Layout.forWeeks(len(weeks))
+	if !layout.Locale.winAnsi() {
+		layout.Locale = englishLocale
+	}
+	cellSize := float64(layout.CellSize)
+	fontSize := float64(layout.labelFontSize())
+	textFill := contrastColor(opts.Theme.Background)
+
+	if layout.Title {
+		page.text(float64(layout.leftMargin()+layout.CellMargin), float64(layout.titleHeight()-layout.labelFontSize()/2), float64(layout.titleFontSize()), textFill, totalHeading(total, opts.Window), pdfAlignLeft)
+	}
+
+	if len(weeks) == 0 {
+		width, height, _ := mapGridSize(0, opts)
+		page.text(float64(width)/2, float64(height)/2, 2*fontSize, textFill, "No contributions", pdfAlignCenter)
+		return
+	}
+	for _, ml := range monthLabels(weeks, layout) {
+		page.text(float64(ml.X), float64(ml.Y), fontSize, textFill, ml.Label, pdfAlignLeft)
+	}
+	if layout.WeekdayLabels {
+		for band := 0; band < layout.gridBands(len(weeks)); band++ {
+			for dayIndex, label := range layout.weekdayLabels() {
+				if label == "" {
+					continue
+				}
+				_, y := layout.cellOrigin(band*layout.weeksPerRow, dayIndex)
+				page.text(0, float64(y)+cellSize/2+fontSize/3, fontSize, textFill, label, pdfAlignLeft)
+			}
+		}
+	}
+	for weekIndex, week := range weeks {
+		for dayIndex, day := range week {
+			x, y := layout.cellOrigin(weekIndex, dayIndex)
+			page.fillRect(float64(x), float64(y), cellSize, cellSize, day.solidColor(opts.Theme.Background))
+		}
+	}
+}
+
+// writeCrossPDF draws the cross diagram with the same geometry as generateCrossSVG.
+func writeCrossPDF(page *pdfPage, crossData CrossData, opts CrossOptions) {
+	crossData = crossData.weighted(opts.Weights)
+	theme := opts.Theme
+	dot := theme.brightestBucket()
+	text := readableOn(theme.midBucket(), theme.Background)
+
+	layout := opts.Layout
+	centerX, centerY := float64(layout.centerX()), float64(layout.centerY())
+	if !theme.Transparent {
+		page.fillRect(0, 0, float64(layout.Width), float64(layout.Height), theme.Background)
+	}
+	page.dashedLine(centerX, 0, centerX, float64(layout.Height), dot)
+	page.dashedLine(0, centerY, float64(layout.Width), centerY, dot)
+	arms := crossArms(crossData, opts)
+	for _, arm := range arms {
+		page.text(float64(arm.X), float64(arm.Y), 14, text, arm.Label, pdfAlignCenter)
+		page.text(float64(arm.X), float64(arm.Y+18), 12, text, fmt.Sprintf("%0.1f%%", arm.Perc), pdfAlignCenter)
+	}
+	x, y := crossDotPosition(arms, opts.Formula, layout)
+	page.fillCircle(x, y, 10, dot)
+}
+
+// Text alignments understood by pdfPage.text.
+const (
+	pdfAlignLeft = iota
+	pdfAlignCenter
+)
+
+// pdfPage accumulates the content stream of one page. Callers use SVG-style
+// coordinates (origin top-left, y down); the page flips them for PDF.
+type pdfPage struct {
+	width, height float64
+	content       bytes.Buffer
+}
+
+func newPDFPage(width, height int) *pdfPage {
+	return &pdfPage{width: float64(width), height: float64(height)}
+}
+
+func (p *pdfPage) fillRect(x, y, w, h float64, hex string) {
+	fmt.Fprintf(&p.content, "%s rg %s %s %s %s re f\n", pdfColor(hex), pdfNum(x), pdfNum(p.height-y-h), pdfNum(w), pdfNum(h))
+}
+
+func (p *pdfPage) dashedLine(x1, y1, x2, y2 float64, hex string) {
+	fmt.Fprintf(&p.content, "%s RG [4] 0 d %s %s m %s %s l S [] 0 d\n", pdfColor(hex), pdfNum(x1), pdfNum(p.height-y1), pdfNum(x2), pdfNum(p.height-y2))
+}
+
+// fillCircle approximates a circle with four cubic Bézier curves.
+func (p *pdfPage) fillCircle(cx, cy, r float64, hex string) {
+	const k = 0.5523 // control-point distance for a quarter circle
+	cy = p.height - cy
+	fmt.Fprintf(&p.content, "%s rg %s %s m ", pdfColor(hex), pdfNum(cx+r), pdfNum(cy))
+	fmt.Fprintf(&p.content, "%s %s %s %s %s %s c ", pdfNum(cx+r), pdfNum(cy+k*r), pdfNum(cx+k*r), pdfNum(cy+r), pdfNum(cx), pdfNum(cy+r))
+	fmt.Fprintf(&p.content, "%s %s %s %s %s %s c ", pdfNum(cx-k*r), pdfNum(cy+r), pdfNum(cx-r), pdfNum(cy+k*r), pdfNum(cx-r), pdfNum(cy))
+	fmt.Fprintf(&p.content, "%s %s %s %s %s %s c ", pdfNum(cx-r), pdfNum(cy-k*r), pdfNum(cx-k*r), pdfNum(cy-r), pdfNum(cx), pdfNum(cy-r))
+	fmt.Fprintf(&p.content, "%s %s %s %s %s %s c f\n", pdfNum(cx+k*r), pdfNum(cy-r), pdfNum(cx+r), pdfNum(cy-k*r), pdfNum(cx+r), pdfNum(cy))
+}
+
+// text draws s with its baseline at y. Centered text uses an average
+// Helvetica glyph width, which is close enough for short labels.
+func (p *pdfPage) text(x, y, size float64, hex, s string, align int) {
+	encoded := winAnsiString(s)
+	if align == pdfAlignCenter {
+		x -= 0.5 * size * float64(len(encoded)) * 0.55
+	}
+	fmt.Fprintf(&p.content, "BT %s rg /F1 %s Tf %s %s Td (%s) Tj ET\n", pdfColor(hex), pdfNum(size), pdfNum(x), pdfNum(p.height-y), pdfEscape(encoded))
+}
+
+// winAnsiExtras are the characters WinAnsiEncoding places in 0x80-0x9F; the
+// rest of 0x20-0xFF matches Latin-1, and so Unicode.
+var winAnsiExtras = map[rune]byte{
+	'€': 0x80, '‚': 0x82, 'ƒ': 0x83, '„': 0x84, '…': 0x85, '†': 0x86, '‡': 0x87, 'ˆ': 0x88,
+	'‰': 0x89, 'Š': 0x8a, '‹': 0x8b, 'Œ': 0x8c, 'Ž': 0x8e, '‘': 0x91, '’': 0x92, '“': 0x93,
+	'”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97, '˜': 0x98, '™': 0x99, 'š': 0x9a, '›': 0x9b,
+	'œ': 0x9c, 'ž': 0x9e, 'Ÿ': 0x9f,
+}
+
+// winAnsiByte returns the WinAnsiEncoding code of r, the encoding the PDF's
+// standard font is declared with, and whether it has one.
+func winAnsiByte(r rune) (byte, bool) {
+	switch {
+	case r >= 0x20 && r < 0x7f, r >= 0xa0 && r <= 0xff:
+		return byte(r), true
+	}
+	b, ok := winAnsiExtras[r]
+	return b, ok
+}
+
+// winAnsiString encodes s in WinAnsiEncoding, replacing characters the
+// encoding lacks with '?'.
+func winAnsiString(s string) string {
+	encoded := make([]byte, 0, len(s))
+	for _, r := range s {
+		b, ok := winAnsiByte(r)
+		if !ok {
+			b = '?'
+		}
+		encoded = append(encoded, b)
+	}
+	return string(encoded)
+}
+
+// encodePDF serializes pages into a complete PDF document.
+func encodePDF(pages []pdfPage) []byte {
+	// Objects: 1 catalog, 2 page tree, 3 font, then a page and a content
+	// stream per page.
+	var objects []string
+	objects = append(objects, "<< /Type /Catalog /Pages 2 0 R >>")
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 4+2*i)
+	}
+	objects = append(objects, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)))
+	objects = append(objects, "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	for i, page := range pages {
+		objects = append(objects, fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>",
+			pdfNum(page.width), pdfNum(page.height), 5+2*i))
+		objects = append(objects, fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", page.content.Len(), page.content.String()))
+	}
+
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return out.Bytes()
+}
+
+// checkPDF reads back the cross-reference table of a PDF from encodePDF: the
+// startxref offset must point at the table, and each entry at the object it
+// numbers. It guards against the offsets drifting from the serialized objects.
+func checkPDF(data []byte) error {
+	tail := data[max(len(data)-64, 0):]
+	i := bytes.LastIndex(tail, []byte("startxref\n"))
+	if i < 0 {
+		return errors.New("no startxref")
+	}
+	fields := strings.Fields(string(tail[i+len("startxref\n"):]))
+	if len(fields) == 0 {
+		return errors.New("no startxref offset")
+	}
+	xref, err := strconv.Atoi(fields[0])
+	if err != nil || xref < 0 || xref >= len(data) || !bytes.HasPrefix(data[xref:], []byte("xref\n")) {
+		return fmt.Errorf("startxref %s does not point at the xref table", fields[0])
+	}
+	lines := strings.Split(string(data[xref:]), "\n")
+	var count int
+	if len(lines) < 3 || !strings.HasPrefix(lines[1], "0 ") {
+		return errors.New("malformed xref subsection header")
+	}
+	if count, err = strconv.Atoi(strings.TrimPrefix(lines[1], "0 ")); err != nil || len(lines) < 2+count {
+		return errors.New("malformed xref subsection header")
+	}
+	// Entry 0 is the head of the free list; the rest are objects 1..count-1.
+	for n := 1; n < count; n++ {
+		offset, err := strconv.Atoi(strings.Fields(lines[2+n])[0])
+		if err != nil || offset < 0 || offset >= xref || !bytes.HasPrefix(data[offset:], []byte(fmt.Sprintf("%d 0 obj\n", n))) {
+			return fmt.Errorf("xref entry for object %d does not point at it", n)
+		}
+	}
+	return nil
+}
+
+// pdfColor converts a hex color into PDF's 0..1 RGB operands.
+func pdfColor(hex string) string {
+	c := hexRGB(hex)
+	return fmt.Sprintf("%s %s %s", pdfNum(float64(c.R)/255), pdfNum(float64(c.G)/255), pdfNum(float64(c.B)/255))
+}
+
+// pdfNum formats a number compactly for a PDF content stream.
+func pdfNum(v float64) string {
+	return strconv.FormatFloat(math.Round(v*1000)/1000, 'f', -1, 64)
+}
+
+// pdfEscape escapes a string for use inside a PDF literal string.
+func pdfEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `(`, `\(`, `)`, `\)`).Replace(s)
+}
+
+// hexRGB converts a validated #RGB or #RRGGBB color into an opaque RGBA value.
+func hexRGB(hex string) color.RGBA {
+	c, _ := parseHexColor(hex)
+	return c
+}
+
+// =============================================================================
+// CSV Export
+// =============================================================================
+
+// generateCSV writes one date,count row per real day, oldest first, after a
+// header row. With several users a leading user column tells them apart.
+func generateCSV(grids []LabeledWeeks, filename string) error {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	multi := len(grids) > 1
+	header := []string{"date", "count"}
+	if multi {
+		header = append([]string{"user"}, header...)
+	}
+	w.Write(header)
+	for _, grid := range grids {
+		for _, week := range grid.Weeks {
+			for _, day := range week {
+				// Padding days carry no date.
+				if day.Date == "" {
+					continue
+				}
+				row := []string{day.Date, strconv.Itoa(day.Count)}
+				if multi {
+					row = append([]string{grid.Label}, row...)
+				}
+				w.Write(row)
+			}
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	return writeOutputFile(filename, buf.Bytes())
+}
+
+// =============================================================================
+// JSON Export and Import (--output json, --input)
+// =============================================================================
+
+// dataFile is the JSON document written by --output json and read by
+// --input. Each user entry has the same fields as a disk cache entry, and a
+// bare cache entry is accepted as input too.
+type dataFile struct {
+	Platform string     `json:"platform,omitempty"`
+	Users    []dataUser `json:"users"`
+}
+
+// dataUser is one user's fetch within a dataFile.
+type dataUser struct {
+	User string `json:"user"`
+	cacheEntry
+	Comparison *windowComparison `json:"comparison,omitempty"` // with --compare-to; not read back by --input
+}
+
+// generateJSON writes the fetched data of every user to filename, with the
+// matching --compare-to comparison when comparisons is not nil.
+func generateJSON(grids []LabeledWeeks, crossByUser []CrossData, comparisons []*windowComparison, platform, filename string) error {
+	file := dataFile{Platform: platform}
+	now := time.Now()
+	for i, grid := range grids {
+		user := dataUser{User: grid.Label, cacheEntry: cacheEntry{FetchedAt: now, Weeks: grid.Weeks, CrossData: crossByUser[i], Total: grid.Total}}
+		if comparisons != nil {
+			user.Comparison = comparisons[i]
+		}
+		file.Users = append(file.Users, user)
+	}
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeOutputFile(filename, append(data, '\n'))
+}
+
+// loadDataFile reads a file written by generateJSON, or a single disk cache
+// entry, and checks that its grids are well formed.
+func loadDataFile(filename string) ([]userFetch, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var file dataFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", filename, err)
+	}
+	if file.Users == nil {
+		var entry cacheEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", filename, err)
+		}
+		// A cache entry does not record the user, so name it after the file.
+		file.Users = []dataUser{{User: strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename)), cacheEntry: entry}}
+	}
+	if len(file.Users) == 0 || file.Users[0].Weeks == nil {
+		return nil, fmt.Errorf("%s holds no contribution data", filename)
+	}
+
+	results := make([]userFetch, len(file.Users))
+	for i, u := range file.Users {
+		if err := validateWeeks(u.Weeks); err != nil {
+			return nil, fmt.Errorf("%s: user %q: %w", filename, u.User, err)
+		}
+		c := u.CrossData
+		if c.Commits < 0 || c.PullRequests < 0 || c.Issues < 0 || c.CodeReviews < 0 {
+			return nil, fmt.Errorf("%s: user %q: negative cross diagram totals", filename, u.User)
+		}
+		if u.Total < 0 {
+			return nil, fmt.Errorf("%s: user %q: negative total", filename, u.User)
+		}
+		results[i] = userFetch{Name: u.User, Weeks: u.Weeks, CrossData: u.CrossData, Total: u.total()}
+	}
+	return results, nil
+}
+
+// validateWeeks checks that every week has at most seven days and that the
+// dated days are valid, in order and have no negative counts. Padding days
+// have an empty date.
+func validateWeeks(weeks Weeks) error {
+	last := ""
+	for i, week := range weeks {
+		if len(week) == 0 || len(week) > 7 {
+			return fmt.Errorf("week %d has %d days", i, len(week))
+		}
+		for _, day := range week {
+			if day.Date == "" {
+				continue
+			}
+			if _, err := time.Parse("2006-01-02", day.Date); err != nil {
+				return fmt.Errorf("invalid date %q", day.Date)
+			}
+			if day.Date <= last {
+				return fmt.Errorf("date %s is out of order", day.Date)
+			}
+			if day.Count < 0 {
+				return fmt.Errorf("negative count on %s", day.Date)
+			}
+			last = day.Date
+		}
+	}
+	return nil
+}
+
+// =============================================================================
+// Raster (WebP) Export
+// =============================================================================
+
+// Largest width or height a lossless WebP image can describe.
+const maxWebPDimension = 1 << 14
+
+// rasterizeMap draws the contribution maps stacked vertically into an image.
+// Only the background and the day cells are drawn: text labels need a font,
+// which the raster path deliberately does without.
+func rasterizeMap(grids []LabeledWeeks, opts MapOptions) (*image.RGBA, error) {
+	width, height := 0, 0
+	for _, grid := range grids {
+		w, h, err := mapGridSize(len(grid.Weeks), opts)
+		if err != nil {
+			return nil, err
+		}
+		if w > width {
+			width = w
+		}
+		height += h
+	}
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	fillRaster(img, img.Bounds(), opts.Theme.Background)
+
+	offsetY := 0
+	for _, grid := range grids {
+		_, h, _ := mapGridSize(len(grid.Weeks), opts)
+		layout := opts.Layout.forWeeks(len(grid.Weeks))
+		for weekIndex, week := range grid.Weeks {
+			for dayIndex, day := range week {
+				x, y := layout.cellOrigin(weekIndex, dayIndex)
+				fillRaster(img, image.Rect(x, offsetY+y, x+opts.Layout.CellSize, offsetY+y+opts.Layout.CellSize), day.solidColor(opts.Theme.Background))
+			}
+		}
+		offsetY += h
+	}
+	return img, nil
+}
+
+// rasterizeCross draws the cross diagram's background, dashed axes and dot.
+// As with rasterizeMap, the text labels are omitted.
+func rasterizeCross(crossData CrossData, opts CrossOptions) *image.RGBA {
+	crossData = crossData.weighted(opts.Weights)
+	theme := opts.Theme
+	layout := opts.Layout
+	img := image.NewRGBA(image.Rect(0, 0, layout.Width, layout.Height))
+	fillRaster(img, img.Bounds(), theme.Background)
+	dot := hexRGB(theme.brightestBucket())
+	for i := 0; i < layout.Width; i++ {
+		if (i/4)%2 == 0 { // 4px dashes, like stroke-dasharray="4"
+			img.SetRGBA(i, layout.centerY(), dot)
+		}
+	}
+	for i := 0; i < layout.Height; i++ {
+		if (i/4)%2 == 0 {
+			img.SetRGBA(layout.centerX(), i, dot)
+		}
+	}
+	cx, cy := crossDotPosition(crossArms(crossData, opts), opts.Formula, layout)
+	for y := int(cy) - 10; y <= int(cy)+10; y++ {
+		for x := int(cx) - 10; x <= int(cx)+10; x++ {
+			dx, dy := float64(x)+0.5-cx, float64(y)+0.5-cy
+			if dx*dx+dy*dy <= 100 {
+				img.SetRGBA(x, y, dot)
+			}
+		}
+	}
+	return img
+}
+
+// writeWebP encodes img as WebP and writes it to filename.
+func writeWebP(filename string, img *image.RGBA) error {
+	data, err := encodeWebP(img)
+	if err != nil {
+		return err
+	}
+	if err := checkWebP(data, img.Bounds().Dx(), img.Bounds().Dy()); err != nil {
+		return fmt.Errorf("generated WebP is malformed: %w", err)
+	}
+	return writeOutputFile(filename, data)
+}
+
+// fillRaster fills rect (clipped to img) with a hex color.
+func fillRaster(img *image.RGBA, rect image.Rectangle, hex string) {
+	c := hexRGB(hex)
+	rect = rect.Intersect(img.Bounds())
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			img.SetRGBA(x, y, c)
+		}
+	}
+}
+
+// encodeWebP encodes img as a lossless (VP8L) WebP. The encoder is minimal but
+// valid: no transforms or backward references, fixed 8-bit prefix codes for
+// the green, red and blue channels and a constant alpha, so the output is
+// roughly three bytes per pixel.
+func encodeWebP(img *image.RGBA) ([]byte, error) {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width < 1 || height < 1 || width > maxWebPDimension || height > maxWebPDimension {
+		return nil, fmt.Errorf("image size %dx%d is outside WebP's 1..%d pixel limit", width, height, maxWebPDimension)
+	}
+
+	var bw webpBitWriter
+	bw.writeBits(0x2f, 8) // VP8L signature
+	bw.writeBits(uint32(width-1), 14)
+	bw.writeBits(uint32(height-1), 14)
+	bw.writeBits(0, 1) // alpha is not used
+	bw.writeBits(0, 3) // version
+	bw.writeBits(0, 1) // no transforms
+	bw.writeBits(0, 1) // no color cache
+	bw.writeBits(0, 1) // no meta prefix codes
+
+	bw.writeFixedLengthCode(256 + 24) // green + backward-reference lengths
+	bw.writeFixedLengthCode(256)      // red
+	bw.writeFixedLengthCode(256)      // blue
+	bw.writeSimpleCode(0xff)          // alpha: always opaque
+	bw.writeSimpleCode(0)             // distance: unused
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := img.RGBAAt(x, y)
+			bw.writeCode(uint32(c.G), 8)
+			bw.writeCode(uint32(c.R), 8)
+			bw.writeCode(uint32(c.B), 8)
+		}
+	}
+	payload := bw.bytes()
+
+	var out bytes.Buffer
+	chunkSize := len(payload)
+	padded := chunkSize + chunkSize%2
+	out.WriteString("RIFF")
+	binary.Write(&out, binary.LittleEndian, uint32(4+8+padded))
+	out.WriteString("WEBPVP8L")
+	binary.Write(&out, binary.LittleEndian, uint32(chunkSize))
+	out.Write(payload)
+	if padded != chunkSize {
+		out.WriteByte(0)
+	}
+	return out.Bytes(), nil
+}
+
+// checkWebP reads back the RIFF container and VP8L header of a WebP from
+// encodeWebP: the chunk sizes must add up to the data and the header must give
+// the VP8L signature and the image's width and height.
+func checkWebP(data []byte, width, height int) error {
+	if len(data) < 25 || string(data[0:4]) != "RIFF" || string(data[8:16]) != "WEBPVP8L" {
+		return errors.New("not a RIFF WEBP file with a VP8L chunk")
+	}
+	if riffSize := int(binary.LittleEndian.Uint32(data[4:8])); riffSize != len(data)-8 {
+		return fmt.Errorf("RIFF size %d does not match the %d bytes that follow it", riffSize, len(data)-8)
+	}
+	chunkSize := int(binary.LittleEndian.Uint32(data[16:20]))
+	if 20+chunkSize+chunkSize%2 != len(data) {
+		return fmt.Errorf("VP8L chunk size %d does not match the file", chunkSize)
+	}
+	if data[20] != 0x2f {
+		return errors.New("missing VP8L signature")
+	}
+	bits := binary.LittleEndian.Uint32(data[21:25])
+	if w, h := int(bits&0x3fff)+1, int(bits>>14&0x3fff)+1; w != width || h != height {
+		return fmt.Errorf("VP8L header gives %dx%d instead of %dx%d", w, h, width, height)
+	}
+	return nil
+}
+
+// webpBitWriter packs bits least-significant first, as VP8L requires.
+type webpBitWriter struct {
+	buf   []byte
+	acc   uint64
+	nbits uint
+}
+
+func (w *webpBitWriter) writeBits(v uint32, n uint) {
+	w.acc |= uint64(v) << w.nbits
+	w.nbits += n
+	for w.nbits >= 8 {
+		w.buf = append(w.buf, byte(w.acc))
+		w.acc >>= 8
+		w.nbits -= 8
+	}
+}
+
+// writeCode writes a prefix code, whose bits are read most-significant first.
+func (w *webpBitWriter) writeCode(code uint32, length uint) {
+	for i := int(length) - 1; i >= 0; i-- {
+		w.writeBits((code>>uint(i))&1, 1)
+	}
+}
+
+// writeFixedLengthCode writes a normal prefix code giving the first 256 symbols
+// of an alphabet of alphabetSize symbols a length of 8 (so each literal's code
+// is the literal itself) and every other symbol a length of 0.
+func (w *webpBitWriter) writeFixedLengthCode(alphabetSize int) {
+	w.writeBits(0, 1) // normal, not simple
+	// The code-length code only uses lengths 0 and 8, each with a 1-bit code.
+	// Its lengths are sent in the fixed order 17, 18, 0, 1, 2, 3, 4, 5, 16, 6,
+	// 7, 8, so twelve entries reach length 8.
+	order := []int{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8}
+	w.writeBits(uint32(len(order)-4), 4)
+	for _, symbol := range order {
+		if symbol == 0 || symbol == 8 {
+			w.writeBits(1, 3)
+		} else {
+			w.writeBits(0, 3)
+		}
+	}
+	w.writeBits(0, 1) // lengths are given for the whole alphabet
+	for i := 0; i < alphabetSize; i++ {
+		// Canonical codes: length 0 is code 0, length 8 is code 1.
+		if i < 256 {
+			w.writeCode(1, 1)
+		} else {
+			w.writeCode(0, 1)
+		}
+	}
+}
+
+// writeSimpleCode writes a simple prefix code with a single 8-bit symbol,
+// which then takes zero bits per occurrence.
+func (w *webpBitWriter) writeSimpleCode(symbol uint32) {
+	w.writeBits(1, 1) // simple
+	w.writeBits(0, 1) // one symbol
+	w.writeBits(1, 1) // 8-bit symbol
+	w.writeBits(symbol, 8)
+}
+
+func (w *webpBitWriter) bytes() []byte {
+	if w.nbits > 0 {
+		return append(w.buf, byte(w.acc))
+	}
+	return w.buf
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// =============================================================================
+// HTML Page (--output html)
+// =============================================================================
+
+// htmlTooltipScript shows the aria-label of the map cell under the pointer in
+// the #tooltip box, which the page's CSS styles; SVG elements cannot carry
+// CSS-generated content themselves.
+const htmlTooltipScript = `const tip = document.getElementById("tooltip");
+document.querySelector(".map")?.addEventListener("mousemove", event => {
+  const cell = event.target.closest("svg [aria-label]");
+  if (!cell || cell.matches(".map > svg")) {
+    tip.hidden = true;
+    return;
+  }
+  tip.textContent = cell.getAttribute("aria-label");
+  tip.style.left = (event.clientX + 12) + "px";
+  tip.style.top = (event.clientY + 12) + "px";
+  tip.hidden = false;
+});
+document.querySelector(".map")?.addEventListener("mouseleave", () => { tip.hidden = true; });`
+
+// generateHTML writes a standalone HTML page with the contribution maps of
+// grids and the cross diagram inlined as SVG; a nil crossData leaves the
+// diagram out. The SVGs are the ones the svg output writes, except that the
+// map cells show their date and count in a styled hover tooltip instead of
+// the browser's <title> tooltip.
+func generateHTML(grids []LabeledWeeks, crossData *CrossData, outputFilename string, opts MapOptions, crossOpts CrossOptions) error {
+	theme := opts.Theme
+	text := contrastColor(theme.Background)
+
+	var page bytes.Buffer
+	page.WriteString("<!DOCTYPE html>\n<html lang=\"en\">\n<head>\n<meta charset=\"utf-8\">\n<title>Contributions</title>\n<style>\n")
+	page.WriteString(fmt.Sprintf("body { margin: 2em; background: %s; color: %s; font-family: sans-serif; }\n", theme.Background, text))
+	page.WriteString("figure { margin: 0 0 2em; }\n")
+	page.WriteString(fmt.Sprintf(".map svg [aria-label]:hover { stroke: %s; stroke-width: 1; }\n", text))
+	page.WriteString(fmt.Sprintf("#tooltip { position: fixed; pointer-events: none; padding: 2px 6px; border-radius: 3px; background: %s; color: %s; font-size: 12px; }\n", text, theme.Background))
+	if light := opts.AutoLight; light != nil {
+		lightText := contrastColor(light.Background)
+		page.WriteString(fmt.Sprintf("@media (prefers-color-scheme: light) { body { background: %s; color: %s; } #tooltip { background: %s; color: %s; } }\n", light.Background, lightText, lightText, light.Background))
+	}
+	page.WriteString("</style>\n</head>\n<body>\n")
+
+	if len(grids) > 0 {
+		opts.StripTooltips = true
+		var svg []byte
+		var err error
+		if len(grids) == 1 {
+			svg, err = renderSVG(grids[0], opts)
+		} else {
+			svg, err = renderMultiSVG(grids, opts)
+		}
+		if err != nil {
+			return err
+		}
+		page.WriteString("<figure class=\"map\">\n")
+		page.Write(svg)
+		page.WriteString("\n</figure>\n")
+	}
+	if crossData != nil {
+		page.WriteString("<figure class=\"cross\">\n")
+		page.Write(renderCrossSVG(*crossData, crossOpts))
+		page.WriteString("\n</figure>\n")
+	}
+
+	page.WriteString("<div id=\"tooltip\" role=\"tooltip\" hidden></div>\n<script>\n")
+	page.WriteString(htmlTooltipScript)
+	page.WriteString("\n</script>\n</body>\n</html>\n")
+	return writeOutputFile(outputFilename, page.Bytes())
+}
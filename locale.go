@@ -0,0 +1,124 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+// =============================================================================
+// Month and Weekday Names (--locale)
+// =============================================================================
+
+// labelLocale holds the short month and weekday names drawn on the maps. The
+// zero value stands for English.
+type labelLocale struct {
+	Months   [12]string // January first
+	Weekdays [7]string  // indexed by time.Weekday
+}
+
+// englishLocale is the default, and the fallback for unknown locales.
+var englishLocale = labelLocale{
+	Months:   [12]string{"Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec"},
+	Weekdays: [7]string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"},
+}
+
+// cjkMonths are the month names shared by Chinese and Japanese.
+var cjkMonths = [12]string{"1月", "2月", "3月", "4月", "5月", "6月", "7月", "8月", "9月", "10月", "11月", "12月"}
+
+// locales are the values of --locale, keyed by language code. Names are cut
+// to three letters where the usual abbreviation is longer, to fit the labels.
+var locales = map[string]labelLocale{
+	"en": englishLocale,
+	"de": {
+		Months:   [12]string{"Jan", "Feb", "Mär", "Apr", "Mai", "Jun", "Jul", "Aug", "Sep", "Okt", "Nov", "Dez"},
+		Weekdays: [7]string{"So", "Mo", "Di", "Mi", "Do", "Fr", "Sa"},
+	},
+	"es": {
+		Months:   [12]string{"ene", "feb", "mar", "abr", "may", "jun", "jul", "ago", "sep", "oct", "nov", "dic"},
+		Weekdays: [7]string{"dom", "lun", "mar", "mié", "jue", "vie", "sáb"},
+	},
+	"fr": {
+		Months:   [12]string{"jan", "fév", "mar", "avr", "mai", "jun", "jul", "aoû", "sep", "oct", "nov", "déc"},
+		Weekdays: [7]string{"dim", "lun", "mar", "mer", "jeu", "ven", "sam"},
+	},
+	"it": {
+		Months:   [12]string{"gen", "feb", "mar", "apr", "mag", "giu", "lug", "ago", "set", "ott", "nov", "dic"},
+		Weekdays: [7]string{"dom", "lun", "mar", "mer", "gio", "ven", "sab"},
+	},
+	"ja": {
+		Months:   cjkMonths,
+		Weekdays: [7]string{"日", "月", "火", "水", "木", "金", "土"},
+	},
+	"ko": {
+		Months:   [12]string{"1월", "2월", "3월", "4월", "5월", "6월", "7월", "8월", "9월", "10월", "11월", "12월"},
+		Weekdays: [7]string{"일", "월", "화", "수", "목", "금", "토"},
+	},
+	"nl": {
+		Months:   [12]string{"jan", "feb", "mrt", "apr", "mei", "jun", "jul", "aug", "sep", "okt", "nov", "dec"},
+		Weekdays: [7]string{"zo", "ma", "di", "wo", "do", "vr", "za"},
+	},
+	"pl": {
+		Months:   [12]string{"sty", "lut", "mar", "kwi", "maj", "cze", "lip", "sie", "wrz", "paź", "lis", "gru"},
+		Weekdays: [7]string{"nd", "pn", "wt", "śr", "cz", "pt", "sb"},
+	},
+	"pt": {
+		Months:   [12]string{"jan", "fev", "mar", "abr", "mai", "jun", "jul", "ago", "set", "out", "nov", "dez"},
+		Weekdays: [7]string{"dom", "seg", "ter", "qua", "qui", "sex", "sáb"},
+	},
+	"ru": {
+		Months:   [12]string{"янв", "фев", "мар", "апр", "май", "июн", "июл", "авг", "сен", "окт", "ноя", "дек"},
+		Weekdays: [7]string{"вс", "пн", "вт", "ср", "чт", "пт", "сб"},
+	},
+	"sv": {
+		Months:   [12]string{"jan", "feb", "mar", "apr", "maj", "jun", "jul", "aug", "sep", "okt", "nov", "dec"},
+		Weekdays: [7]string{"sön", "mån", "tis", "ons", "tor", "fre", "lör"},
+	},
+	"zh": {
+		Months:   cjkMonths,
+		Weekdays: [7]string{"日", "一", "二", "三", "四", "五", "六"},
+	},
+}
+
+// lookupLocale returns the names for a locale such as fr, de-CH or
+// pt_BR.UTF-8, matched by language only. ok is false for unknown languages,
+// which get English.
+func lookupLocale(tag string) (locale labelLocale, ok bool) {
+	language := strings.ToLower(strings.TrimSpace(tag))
+	if i := strings.IndexAny(language, "-_."); i >= 0 {
+		language = language[:i]
+	}
+	locale, ok = locales[language]
+	if !ok {
+		return englishLocale, false
+	}
+	return locale, true
+}
+
+// month returns the short name of m.
+func (l labelLocale) month(m time.Month) string {
+	if l == (labelLocale{}) {
+		l = englishLocale
+	}
+	return l.Months[m-1]
+}
+
+// weekday returns the short name of d.
+func (l labelLocale) weekday(d time.Weekday) string {
+	if l == (labelLocale{}) {
+		l = englishLocale
+	}
+	return l.Weekdays[d]
+}
+
+// winAnsi reports whether every name of l can be drawn with the standard PDF
+// font, whose WinAnsiEncoding covers Western European languages only.
+func (l labelLocale) winAnsi() bool {
+	for _, name := range append(l.Months[:], l.Weekdays[:]...) {
+		for _, r := range name {
+			if _, ok := winAnsiByte(r); !ok {
+				return false
+			}
+		}
+	}
+	return true
+}
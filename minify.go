@@ -0,0 +1,40 @@
+package main
+
+import (
+	"regexp"
+)
+
+// =============================================================================
+// SVG Minification (--minify)
+// =============================================================================
+
+var (
+	// Whitespace between two tags; text content is never whitespace-only.
+	svgInterTagSpace = regexp.MustCompile(`>\s+<`)
+	// A cell or bar tooltip: a <title> directly inside a <rect> or <use>.
+	svgRectTitle = regexp.MustCompile(`(<(?:rect|use) [^>]*>)\s*<title>[^<]*</title>`)
+	// A cell left without children once its tooltip is gone.
+	svgEmptyRect = regexp.MustCompile(`(<(?:rect|use) [^>]*[^/])>\s*</(?:rect|use)>`)
+)
+
+// finishSVG returns data minified when minify is set, logging the saving, and
+// otherwise unchanged. With stripTooltips the per-cell <title> tooltips are
+// dropped as well; the cells keep their aria-label, and the document title
+// stays.
+func finishSVG(data []byte, minify, stripTooltips bool) []byte {
+	if !minify && !stripTooltips {
+		return data
+	}
+	before := len(data)
+	if stripTooltips {
+		data = svgRectTitle.ReplaceAll(data, []byte("$1"))
+		data = svgEmptyRect.ReplaceAll(data, []byte("$1/>"))
+	}
+	if minify {
+		data = svgInterTagSpace.ReplaceAll(data, []byte("><"))
+	}
+	if before > 0 {
+		verboseLog.Printf("SVG output reduced from %d to %d bytes (%.1f%% smaller)", before, len(data), 100*float64(before-len(data))/float64(before))
+	}
+	return data
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"os/exec"
+	"runtime"
+)
+
+// =============================================================================
+// Opening the Output (--open)
+// =============================================================================
+
+// openInViewer opens filename in the default application for its type, without
+// waiting for it to exit.
+func openInViewer(filename string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", filename)
+	case "windows":
+		// start is a cmd built-in; its first quoted argument is a window title.
+		cmd = exec.Command("cmd", "/c", "start", "", filename)
+	default:
+		cmd = exec.Command("xdg-open", filename)
+	}
+	verboseLog.Printf("Opening %s with %s", filename, cmd.Path)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	return cmd.Process.Release()
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// =============================================================================
+// Fetch Progress on stderr
+// =============================================================================
+
+// fetchProgress keeps a single line on stderr up to date with the users
+// fetched and the pages requested so far, so that a slow server or a long
+// list of users does not look like a hang. A nil *fetchProgress, the default,
+// shows nothing; its methods are safe to call from several fetches at once.
+type fetchProgress struct {
+	mu       sync.Mutex
+	w        io.Writer
+	active   bool
+	users    int // fetched so far
+	total    int // to fetch
+	pages    int // requested so far, across all users
+	lastSize int // length of the line last drawn, for clearing it
+}
+
+// progress is the indicator for this run, set up by main when stderr is a
+// terminal and none of --quiet, --verbose or --dump-query is given.
+var progress *fetchProgress
+
+// stderrIsTerminal reports whether stderr is a character device rather than a
+// file or pipe.
+func stderrIsTerminal() bool {
+	info, err := os.Stderr.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// start shows the line for fetching total users. Pages requested before start
+// or after finish are not counted, so background refreshes stay silent.
+func (p *fetchProgress) start(total int) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.active, p.users, p.total, p.pages = true, 0, total, 0
+	p.draw()
+}
+
+// pageFetched counts one more page of a paginated feed.
+func (p *fetchProgress) pageFetched() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.active {
+		p.pages++
+		p.draw()
+	}
+}
+
+// userDone counts one more user as fetched, or failed.
+func (p *fetchProgress) userDone() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.active {
+		p.users++
+		p.draw()
+	}
+}
+
+// clear erases the line so that a message can be printed in its place; the
+// next update draws it again.
+func (p *fetchProgress) clear() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.erase()
+}
+
+// finish erases the line for good.
+func (p *fetchProgress) finish() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.erase()
+	p.active = false
+}
+
+func (p *fetchProgress) draw() {
+	line := fmt.Sprintf("Fetching contributions: %d/%d users", p.users, p.total)
+	if p.pages > 0 {
+		line += fmt.Sprintf(", %d pages", p.pages)
+	}
+	// Pad over the remains of a longer previous line.
+	padding := ""
+	if n := p.lastSize - len(line); n > 0 {
+		padding = strings.Repeat(" ", n)
+	}
+	fmt.Fprint(p.w, "\r"+line+padding)
+	p.lastSize = len(line)
+}
+
+func (p *fetchProgress) erase() {
+	if p.lastSize > 0 {
+		fmt.Fprint(p.w, "\r"+strings.Repeat(" ", p.lastSize)+"\r")
+		p.lastSize = 0
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"sync"
+	"time"
+)
+
+// =============================================================================
+// Multi-Year Ranges (--range)
+// =============================================================================
+
+// years splits w into consecutive windows of at most a year each, the most
+// GitHub serves per query, in chronological order.
+func (w dateWindow) years() []dateWindow {
+	var years []dateWindow
+	for from := w.From; !from.After(w.To); from = from.AddDate(1, 0, 0) {
+		to := from.AddDate(1, 0, -1)
+		if to.After(w.To) {
+			to = w.To
+		}
+		years = append(years, dateWindow{From: from, To: to})
+	}
+	return years
+}
+
+// fetchRange is fetchWindow for a window of any length. Its years are fetched
+// on at most concurrency workers and laid out as one grid in chronological
+// order, with the totals and breakdowns summed. With stopOnError, the first
+// failure cancels the years still in flight and is returned; otherwise a year
+// that fails is reported on stderr and left empty, and only a range whose
+// every year fails is an error.
+func (c fetchConfig) fetchRange(ctx context.Context, username string, window dateWindow, concurrency int, stopOnError bool) (Weeks, CrossData, int, error) {
+	years := window.years()
+	labels := make([]string, len(years))
+	byLabel := make(map[string]dateWindow, len(years))
+	for i, year := range years {
+		labels[i] = year.String()
+		byLabel[labels[i]] = year
+	}
+	results := fetchUsers(ctx, labels, concurrency, stopOnError, func(ctx context.Context, label string) userFetch {
+		weeks, crossData, total, err := c.fetchWindow(ctx, username, byLabel[label])
+		return userFetch{Name: label, Weeks: weeks, CrossData: crossData, Total: total, Err: err}
+	})
+
+	counts := make(map[string]int)
+	var crossData CrossData
+	var total int
+	var firstErr error
+	for _, result := range results {
+		if result.Err != nil {
+			if stopOnError || ctx.Err() != nil {
+				return nil, CrossData{}, 0, result.Err
+			}
+			if firstErr == nil {
+				firstErr = result.Err
+			}
+			progress.clear()
+			fmt.Fprintf(os.Stderr, "%v\n", result.Err)
+			fmt.Fprintf(os.Stderr, "Leaving %s empty for %s.\n", result.Name, username)
+			continue
+		}
+		for _, week := range result.Weeks {
+			for _, day := range week {
+				if day.Date != "" {
+					counts[day.Date] += day.Count
+				}
+			}
+		}
+		crossData = crossData.add(result.CrossData)
+		total += result.Total
+	}
+	if firstErr != nil && len(counts) == 0 {
+		return nil, CrossData{}, 0, firstErr
+	}
+	return buildWeeks(counts, window.From, window.To), crossData, total, nil
+}
+
+// gitHubRateLimiter paces GitHub requests by the rate limit its responses
+// last reported, so that the years of a --range fetched in parallel do not
+// run past it. Until a response reports the limit, one request at a time is
+// let through to learn it; one that ends without learning it, as on servers
+// without a rate limit, leaves the requests unpaced.
+type gitHubRateLimiter struct {
+	mu        sync.Mutex
+	known     bool
+	remaining int // points left, less the requests started since they were reported
+	reset     time.Time
+	probe     chan struct{} // closed when the request learning the limit is done
+	announced time.Time     // reset last waited for, so the wait is noted once
+}
+
+// gitHubRateLimit is the limit shared by this run's GitHub requests.
+var gitHubRateLimit gitHubRateLimiter
+
+// observe records the X-RateLimit headers of a response. Responses to
+// requests that overlapped can arrive out of order, so within one reset
+// period the lowest count seen is kept.
+func (l *gitHubRateLimiter) observe(remaining int, reset time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.known || !reset.Equal(l.reset) || remaining < l.remaining {
+		l.remaining = remaining
+	}
+	l.known, l.reset = true, reset
+	l.endProbe()
+}
+
+// endProbe lets the requests waiting on the limit proceed. l.mu is held.
+func (l *gitHubRateLimiter) endProbe() {
+	if l.probe != nil {
+		close(l.probe)
+		l.probe = nil
+	}
+}
+
+// wait counts one more request against the limit, first waiting for it to
+// be known and, if no points are left, to reset. The returned done is called
+// once the request has been answered. Canceling ctx abandons the wait.
+func (l *gitHubRateLimiter) wait(ctx context.Context) (done func(), err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for {
+		var until <-chan time.Time
+		switch {
+		case !l.known && l.probe == nil:
+			probe := make(chan struct{})
+			l.probe = probe
+			return func() {
+				l.mu.Lock()
+				defer l.mu.Unlock()
+				if l.probe == probe {
+					l.known, l.remaining = true, math.MaxInt
+					l.endProbe()
+				}
+			}, nil
+		case !l.known:
+			// Wait for the request learning the limit.
+		case l.remaining <= 0 && time.Now().Before(l.reset):
+			if !l.reset.Equal(l.announced) {
+				l.announced = l.reset
+				progress.clear()
+				fmt.Fprintf(statusOut, "GitHub API rate limit reached; waiting until it resets at %s (in %s)...\n", l.reset.Local().Format(time.RFC1123), time.Until(l.reset).Round(time.Second))
+			}
+			until = time.After(time.Until(l.reset))
+		default:
+			l.remaining--
+			return func() {}, nil
+		}
+		probe, reset := l.probe, l.reset
+		l.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			l.mu.Lock()
+			return nil, ctx.Err()
+		case <-probe:
+		case <-until:
+		}
+		l.mu.Lock()
+		if until != nil && l.reset.Equal(reset) {
+			// The limit has renewed; the next response reports it.
+			l.known = false
+		}
+	}
+}
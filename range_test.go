@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDateWindowYears(t *testing.T) {
+	window, err := parseDateWindow("2022-03-10:2024-12-31")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, year := range window.years() {
+		got = append(got, year.String())
+	}
+	want := "2022-03-10 to 2023-03-09, 2023-03-10 to 2024-03-09, 2024-03-10 to 2024-12-31"
+	if strings.Join(got, ", ") != want {
+		t.Errorf("years = %s, want %s", strings.Join(got, ", "), want)
+	}
+}
+
+// rangeServer answers GitHub window queries with one contribution a day and
+// fails the windows starting in failYear, if nonzero.
+func rangeServer(t *testing.T, failYear int) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Variables struct {
+				From, To string
+			} `json:"variables"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		from, _ := time.Parse(time.RFC3339, body.Variables.From)
+		to, _ := time.Parse(time.RFC3339, body.Variables.To)
+		w.Header().Set("Content-Type", "application/json")
+		if from.Year() == failYear {
+			w.WriteHeader(http.StatusBadGateway)
+			fmt.Fprint(w, `{"message": "bad gateway"}`)
+			return
+		}
+		var days []string
+		for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+			days = append(days, fmt.Sprintf(`{"date": %q, "contributionCount": 1}`, d.Format("2006-01-02")))
+		}
+		fmt.Fprintf(w, `{"data": {"user": {"contributionsCollection": {"totalCommitContributions": %d,
+			"contributionCalendar": {"totalContributions": %d, "weeks": [{"contributionDays": [%s]}]}}}}}`,
+			len(days), len(days), strings.Join(days, ","))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestFetchRange(t *testing.T) {
+	window, _ := parseDateWindow("2021-01-01:2023-06-30")
+	c := fetchConfig{Platform: "github", GitHubURL: rangeServer(t, 0).URL, Token: "secret"}
+	weeks, crossData, total, err := c.fetchRange(context.Background(), "octo", window, 3, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dates []string
+	for _, week := range weeks {
+		for _, day := range week {
+			if day.Date != "" {
+				dates = append(dates, day.Date)
+				if day.Count != 1 {
+					t.Errorf("%s has %d contributions, want 1", day.Date, day.Count)
+				}
+			}
+		}
+	}
+	days := int(window.To.Sub(window.From).Hours()/24) + 1
+	if len(dates) != days || dates[0] != "2021-01-01" || dates[len(dates)-1] != "2023-06-30" {
+		t.Errorf("%d days from %s to %s, want %d from 2021-01-01 to 2023-06-30", len(dates), dates[0], dates[len(dates)-1], days)
+	}
+	for i := 1; i < len(dates); i++ {
+		if dates[i] <= dates[i-1] {
+			t.Errorf("%s follows %s", dates[i], dates[i-1])
+		}
+	}
+	if total != days || crossData.Commits != days {
+		t.Errorf("total %d and %d commits, want %d summed over the years", total, crossData.Commits, days)
+	}
+}
+
+func TestFetchRangeFailure(t *testing.T) {
+	window, _ := parseDateWindow("2020-01-01:2023-12-31")
+	c := fetchConfig{Platform: "github", GitHubURL: rangeServer(t, 2021).URL, Token: "secret"}
+	if _, _, _, err := c.fetchRange(context.Background(), "octo", window, 2, true); err == nil || !strings.Contains(err.Error(), "2021-01-01") {
+		t.Errorf("err = %v, want the failure of 2021", err)
+	}
+
+	weeks, _, total, err := c.fetchRange(context.Background(), "octo", window, 2, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := 366 + 365 + 365; total != want {
+		t.Errorf("total = %d, want %d without 2021", total, want)
+	}
+	for _, week := range weeks {
+		for _, day := range week {
+			if strings.HasPrefix(day.Date, "2021") && day.Count != 0 {
+				t.Errorf("%s has %d contributions in the failed year", day.Date, day.Count)
+			}
+		}
+	}
+}
+
+func TestGitHubRateLimiter(t *testing.T) {
+	var l gitHubRateLimiter
+	ctx := context.Background()
+
+	// Until the limit is known, one request at a time learns it.
+	done, err := l.wait(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second := make(chan struct{})
+	go func() {
+		if done, err := l.wait(ctx); err == nil {
+			done()
+		}
+		close(second)
+	}()
+	select {
+	case <-second:
+		t.Fatal("a second request started before the limit was known")
+	case <-time.After(20 * time.Millisecond):
+	}
+	l.observe(1, time.Now().Add(time.Hour))
+	done()
+	<-second
+
+	// The second request took the last point; the next waits for the reset.
+	ctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if _, err := l.wait(ctx); err != context.DeadlineExceeded {
+		t.Errorf("err = %v with no points left, want the deadline", err)
+	}
+
+	l.observe(0, time.Now().Add(30*time.Millisecond))
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			done, err := l.wait(context.Background())
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			l.observe(10, time.Now().Add(time.Hour))
+			done()
+		}()
+	}
+	wg.Wait()
+	if elapsed := time.Since(start); elapsed < 25*time.Millisecond {
+		t.Errorf("requests went out %s after the limit was spent, before its reset", elapsed)
+	}
+
+	// A server that reports no limit is not paced once that is known.
+	var unreported gitHubRateLimiter
+	done, err = unreported.wait(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	done()
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	for i := 0; i < 10; i++ {
+		if _, err := unreported.wait(ctx); err != nil {
+			t.Fatalf("request %d without a reported limit: %v", i+2, err)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// =============================================================================
+// HTTP Server (--serve)
+// =============================================================================
+
+// server renders maps on demand and exposes metrics for a fixed user list.
+type server struct {
+	cfg       fetchConfig
+	mapOpts   MapOptions
+	crossOpts CrossOptions
+	scale     ColorScale
+	ttl       time.Duration
+
+	mu    sync.Mutex
+	cache map[string]servedFetch // keyed by platform and user
+}
+
+// maxServedFetches bounds the server's cache, so that requests for many
+// different users cannot grow it without limit.
+const maxServedFetches = 256
+
+// servedFetch is one colored fetch kept for ttl between map requests.
+type servedFetch struct {
+	Weeks     Weeks
+	CrossData CrossData
+	Total     int
+	FetchedAt time.Time
+}
+
+func newServer(cfg fetchConfig, mapOpts MapOptions, crossOpts CrossOptions, scale ColorScale, ttl time.Duration) *server {
+	return &server{
+		cfg:       cfg,
+		mapOpts:   mapOpts,
+		crossOpts: crossOpts,
+		scale:     scale,
+		ttl:       ttl,
+		cache:     make(map[string]servedFetch),
+	}
+}
+
+// serve listens on addr until ctx is canceled. GET /map and /cross render
+// SVGs for ?user=X, optionally with &platform=Y naming the configured
+// platform; the token always comes from the server's own configuration, never
+// the query string, and is only ever sent to that platform. When users is non-empty they are
+// refetched every refresh interval for /metrics, where a failed fetch keeps
+// the previous values and increments that user's error counter.
+func (s *server) serve(ctx context.Context, addr string, users []string, refresh time.Duration) error {
+	store := &metricsStore{users: users, byUser: make(map[string]userMetrics), errors: make(map[string]int)}
+
+	if len(users) > 0 {
+		go func() {
+			ticker := time.NewTicker(refresh)
+			defer ticker.Stop()
+			for {
+				store.refresh(ctx, s.cfg)
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+			}
+		}()
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		store.writeMetrics(w, s.cfg.Platform)
+	})
+	mux.HandleFunc("/map", func(w http.ResponseWriter, r *http.Request) {
+		s.handleSVG(w, r, func(f servedFetch) ([]byte, error) {
+			return renderSVG(LabeledWeeks{Weeks: f.Weeks, Total: f.Total, CrossData: f.CrossData}, s.mapOpts)
+		})
+	})
+	mux.HandleFunc("/cross", func(w http.ResponseWriter, r *http.Request) {
+		s.handleSVG(w, r, func(f servedFetch) ([]byte, error) {
+			return renderCrossSVG(f.CrossData, s.crossOpts), nil
+		})
+	})
+	httpServer := &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		httpServer.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Fprintf(statusOut, "Serving /map, /cross and /metrics on %s\n", addr)
+	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// handleSVG answers a /map or /cross request with the SVG produced by render.
+func (s *server) handleSVG(w http.ResponseWriter, r *http.Request, render func(servedFetch) ([]byte, error)) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	username := strings.TrimSpace(query.Get("user"))
+	if username == "" {
+		http.Error(w, "missing user parameter", http.StatusBadRequest)
+		return
+	}
+	// The server's token belongs to its configured platform; fetching from
+	// another one would send it to that host.
+	if platform := query.Get("platform"); platform != "" && platform != s.cfg.Platform {
+		http.Error(w, fmt.Sprintf("platform %q is not served here, only %s", platform, s.cfg.Platform), http.StatusBadRequest)
+		return
+	}
+	cfg := s.cfg
+
+	if cfg.Platform == "github" && cfg.Token == "" {
+		http.Error(w, "no GitHub token configured on the server", http.StatusServiceUnavailable)
+		return
+	}
+
+	fetched, err := s.fetch(r.Context(), cfg, username)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	data, err := render(fetched)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Write(data)
+}
+
+// fetch returns the colored data for username, reusing a fetch younger than
+// the server's ttl.
+func (s *server) fetch(ctx context.Context, cfg fetchConfig, username string) (servedFetch, error) {
+	key := cfg.Platform + "\x00" + cfg.instance() + "\x00" + username
+	s.mu.Lock()
+	cached, ok := s.cache[key]
+	s.mu.Unlock()
+	if ok && time.Since(cached.FetchedAt) < s.ttl {
+		verboseLog.Printf("serving %s on %s from memory", username, cfg.Platform)
+		return cached, nil
+	}
+
+	weeks, crossData, total, err := cfg.fetch(ctx, username)
+	if err != nil {
+		return servedFetch{}, err
+	}
+	weeks = startWeeksOn(weeks, s.mapOpts.Layout.WeekStart)
+	updateWeeksColors(weeks, s.mapOpts.Theme, s.scale)
+	fetched := servedFetch{Weeks: weeks, CrossData: crossData, Total: total, FetchedAt: time.Now()}
+	s.mu.Lock()
+	s.store(key, fetched)
+	s.mu.Unlock()
+	return fetched, nil
+}
+
+// store adds fetched to the cache, first dropping entries older than the ttl
+// and, if the cache is still full, the oldest one. The caller holds s.mu.
+func (s *server) store(key string, fetched servedFetch) {
+	var oldest string
+	for k, f := range s.cache {
+		if time.Since(f.FetchedAt) >= s.ttl {
+			delete(s.cache, k)
+		} else if oldest == "" || f.FetchedAt.Before(s.cache[oldest].FetchedAt) {
+			oldest = k
+		}
+	}
+	if _, ok := s.cache[key]; !ok && len(s.cache) >= maxServedFetches {
+		delete(s.cache, oldest)
+	}
+	s.cache[key] = fetched
+}
+
+// userMetrics holds the latest successful fetch for one user.
+type userMetrics struct {
+	Total       int
+	CrossData   CrossData
+	LastSuccess time.Time
+}
+
+// metricsStore is the shared state between the refresh loop and /metrics.
+type metricsStore struct {
+	mu     sync.RWMutex
+	users  []string
+	byUser map[string]userMetrics
+	errors map[string]int // failed refreshes per user
+}
+
+// refresh fetches every user once and records the results.
+func (s *metricsStore) refresh(ctx context.Context, cfg fetchConfig) {
+	for _, name := range s.users {
+		_, crossData, total, err := cfg.fetch(ctx, name)
+		s.mu.Lock()
+		if err != nil {
+			s.errors[name]++
+			s.mu.Unlock()
+			if ctx.Err() != nil {
+				return
+			}
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			continue
+		}
+		s.byUser[name] = userMetrics{
+			Total:       total,
+			CrossData:   crossData,
+			LastSuccess: time.Now(),
+		}
+		s.mu.Unlock()
+	}
+}
+
+// writeMetrics writes all gauges in the Prometheus text exposition format.
+// Users that have never been fetched successfully only report fetch errors.
+func (s *metricsStore) writeMetrics(w http.ResponseWriter, platform string) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	gauges := []struct {
+		name, help string
+		value      func(userMetrics) float64
+	}{
+		{"contribmap_contributions", "Contributions in the trailing year.", func(m userMetrics) float64 { return float64(m.Total) }},
+		{"contribmap_commits", "Commit contributions in the trailing year.", func(m userMetrics) float64 { return float64(m.CrossData.Commits) }},
+		{"contribmap_pull_requests", "Pull request contributions in the trailing year.", func(m userMetrics) float64 { return float64(m.CrossData.PullRequests) }},
+		{"contribmap_issues", "Issue contributions in the trailing year.", func(m userMetrics) float64 { return float64(m.CrossData.Issues) }},
+		{"contribmap_code_reviews", "Code review contributions in the trailing year.", func(m userMetrics) float64 { return float64(m.CrossData.CodeReviews) }},
+		{"contribmap_last_success_timestamp_seconds", "Unix time of the last successful fetch.", func(m userMetrics) float64 { return float64(m.LastSuccess.Unix()) }},
+	}
+	for _, g := range gauges {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", g.name, g.help, g.name)
+		for _, name := range s.users {
+			if m, ok := s.byUser[name]; ok {
+				fmt.Fprintf(w, "%s{%s} %s\n", g.name, metricLabels(platform, name), strconv.FormatFloat(g.value(m), 'f', -1, 64))
+			}
+		}
+	}
+	fmt.Fprintf(w, "# HELP contribmap_fetch_errors_total Failed fetches since start.\n# TYPE contribmap_fetch_errors_total counter\n")
+	for _, name := range s.users {
+		fmt.Fprintf(w, "contribmap_fetch_errors_total{%s} %d\n", metricLabels(platform, name), s.errors[name])
+	}
+}
+
+// metricLabels formats the platform and user labels, escaping values as the
+// exposition format requires.
+func metricLabels(platform, user string) string {
+	escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return fmt.Sprintf(`platform="%s",user="%s"`, escape.Replace(platform), escape.Replace(user))
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// =============================================================================
+// Contribution Sources
+// =============================================================================
+
+// Source is one --platform value: the defaults it fills into a fetchConfig
+// and where its contributions come from.
+type Source interface {
+	// Title is the platform's name in messages, e.g. "GitHub".
+	Title() string
+	// Configure fills in the platform's defaults, such as its event feed.
+	Configure(c *fetchConfig)
+	// Server returns the URL fetches are announced with, or "" to leave it out.
+	Server(c fetchConfig) string
+	// Instance identifies the server, and the time zone days are bucketed in
+	// where the platform depends on it, for cache keys.
+	Instance(c fetchConfig) string
+	// Fetch returns username's trailing-year contributions with colors left
+	// empty, and the year's total. Every platform so far yields the daily
+	// counts and the breakdown from the same pass over its API, so both come
+	// back from one call.
+	Fetch(ctx context.Context, c fetchConfig, username string) (Weeks, CrossData, int, error)
+	Check(ctx context.Context, c fetchConfig, out io.Writer) error
+}
+
+// windowFetcher is implemented by the sources that can fetch an earlier
+// window of days, for --compare-to and --range.
+type windowFetcher interface {
+	FetchWindow(ctx context.Context, c fetchConfig, username string, window dateWindow) (Weeks, CrossData, int, error)
+}
+
+// revalidator is implemented by the sources that support conditional
+// requests, so that an expired cache entry can be revalidated instead of
+// refetched. FetchIfNoneMatch is Fetch sending etag, when nonempty, as
+// If-None-Match: it returns errNotModified if the contributions are
+// unchanged, and otherwise the response's ETag with them.
+type revalidator interface {
+	FetchIfNoneMatch(ctx context.Context, c fetchConfig, username, etag string) (Weeks, CrossData, int, string, error)
+}
+
+// dayLinker is implemented by the sources with a web page listing a user's
+// contributions on one day, for --link.
+type dayLinker interface {
+	DayURL(c fetchConfig, username, date string) string
+}
+
+// githubSource reads the contribution calendar of the GraphQL API.
+type githubSource struct{}
+
+func (githubSource) Title() string                 { return "GitHub" }
+func (githubSource) Configure(c *fetchConfig)      {}
+func (githubSource) Server(c fetchConfig) string   { return "" }
+func (githubSource) Instance(c fetchConfig) string { return c.GitHubURL }
+
+func (githubSource) Fetch(ctx context.Context, c fetchConfig, username string) (Weeks, CrossData, int, error) {
+	weeks, crossData, total, _, err := fetchGitHubContributions(ctx, c.GitHubURL, username, c.Token, nil, "", c.LightMode)
+	return weeks, crossData, total, err
+}
+
+func (githubSource) FetchIfNoneMatch(ctx context.Context, c fetchConfig, username, etag string) (Weeks, CrossData, int, string, error) {
+	return fetchGitHubContributions(ctx, c.GitHubURL, username, c.Token, nil, etag, c.LightMode)
+}
+
+// FetchWindow waits out an exhausted rate limit first, as the windows of a
+// --range are fetched in parallel.
+func (githubSource) FetchWindow(ctx context.Context, c fetchConfig, username string, window dateWindow) (Weeks, CrossData, int, error) {
+	done, err := gitHubRateLimit.wait(ctx)
+	if err != nil {
+		return nil, CrossData{}, 0, err
+	}
+	defer done()
+	weeks, crossData, total, _, err := fetchGitHubContributions(ctx, c.GitHubURL, username, c.Token, &window, "", c.LightMode)
+	return weeks, crossData, total, err
+}
+
+func (githubSource) Check(ctx context.Context, c fetchConfig, out io.Writer) error {
+	return checkGitHub(ctx, c, out)
+}
+
+func (githubSource) DayURL(c fetchConfig, username, date string) string {
+	return githubWebURL(c.GitHubURL) + "/" + url.PathEscape(username) + "?tab=overview&from=" + date + "&to=" + date
+}
+
+// giteaSource reads the event feed of a Gitea-style instance. Gitea has no
+// yearly total of its own, so the daily counts are summed.
+type giteaSource struct {
+	title      string
+	eventsPath string // giteaEventsPath or forgejoEventsPath
+	defaultURL string // used unless --gitea-url is given; "" keeps its default
+}
+
+func (s giteaSource) Title() string { return s.title }
+
+func (s giteaSource) Configure(c *fetchConfig) {
+	c.EventsPath = s.eventsPath
+	if s.defaultURL != "" && !c.GiteaURLExplicit {
+		c.GiteaURL = s.defaultURL
+	}
+}
+
+func (giteaSource) Server(c fetchConfig) string   { return c.GiteaURL }
+func (giteaSource) Instance(c fetchConfig) string { return c.GiteaURL + " " + c.Location.String() }
+
+func (giteaSource) Fetch(ctx context.Context, c fetchConfig, username string) (Weeks, CrossData, int, error) {
+	weeks, crossData, err := fetchGiteaContributions(ctx, username, c.GiteaURL, c.EventsPath, c.Token, c.Location, c.LightMode)
+	return weeks, crossData, computeStats(weeks).TotalContributions, err
+}
+
+func (giteaSource) Check(ctx context.Context, c fetchConfig, out io.Writer) error {
+	return checkGitea(ctx, c, out)
+}
+
+func (giteaSource) DayURL(c fetchConfig, username, date string) string {
+	return strings.TrimSuffix(c.GiteaURL, "/") + "/" + url.PathEscape(username) + "?tab=activity&date=" + date
+}
+
+// bitbucketSource approximates the graph from the Bitbucket Cloud REST API
+// (see fetchBitbucketContributions); the daily counts are summed for the total.
+type bitbucketSource struct{}
+
+func (bitbucketSource) Title() string               { return "Bitbucket" }
+func (bitbucketSource) Configure(c *fetchConfig)    {}
+func (bitbucketSource) Server(c fetchConfig) string { return c.BitbucketURL }
+func (bitbucketSource) Instance(c fetchConfig) string {
+	return c.BitbucketURL + " " + c.Location.String()
+}
+
+func (bitbucketSource) Fetch(ctx context.Context, c fetchConfig, username string) (Weeks, CrossData, int, error) {
+	weeks, crossData, err := fetchBitbucketContributions(ctx, c.BitbucketURL, username, c.Token, c.Location)
+	return weeks, crossData, computeStats(weeks).TotalContributions, err
+}
+
+func (bitbucketSource) Check(ctx context.Context, c fetchConfig, out io.Writer) error {
+	return checkBitbucket(ctx, c, out)
+}
+
+// platforms are the values of --platform.
+var platforms = map[string]Source{
+	"github":    githubSource{},
+	"bitbucket": bitbucketSource{},
+	"gitea":     giteaSource{title: "Gitea", eventsPath: giteaEventsPath},
+	"forgejo":   giteaSource{title: "Forgejo", eventsPath: forgejoEventsPath},
+	"codeberg":  giteaSource{title: "Codeberg", eventsPath: forgejoEventsPath, defaultURL: codebergURL},
+}
+
+// githubWebURL returns the web address of the GitHub instance serving the
+// GraphQL endpoint: github.com for api.github.com, otherwise the endpoint's
+// host, as on GitHub Enterprise Server.
+func githubWebURL(endpoint string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "api.github.com" {
+		return "https://github.com"
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// platformNames returns the names of the platforms in sorted order.
+func platformNames() []string {
+	names := make([]string, 0, len(platforms))
+	for name := range platforms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
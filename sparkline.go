@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// =============================================================================
+// Sparkline of Monthly Totals (--output sparkline)
+// =============================================================================
+
+// Sparkline geometry: the default width (overridable with --sparkline-width),
+// the height of one line and the padding that keeps the stroke and dots
+// inside the image.
+const (
+	defaultSparklineWidth = 120
+	sparklineHeight       = 24
+	sparklinePadding      = 3
+)
+
+// generateSparklineSVG writes one sparkline of monthly totals per grid,
+// stacked vertically, to outputFilename.
+func generateSparklineSVG(grids []LabeledWeeks, width int, dots bool, outputFilename string, opts MapOptions) error {
+	if width < 4*sparklinePadding || width > maxSVGDimension {
+		return fmt.Errorf("sparkline width must be between %d and %d, got %d", 4*sparklinePadding, maxSVGDimension, width)
+	}
+	height := len(grids) * sparklineHeight
+
+	var svg bytes.Buffer
+	writeSVGHeader(&svg, width, height, "Contributions per month", multiMapSummary(grids))
+	opts.Theme.writeBackground(&svg, width, height)
+	for i, grid := range grids {
+		writeSparkline(&svg, aggregatePeriods(grid.Weeks, granularityMonthly, opts.Layout.Locale), width, i*sparklineHeight, dots, opts.Theme)
+	}
+	svg.WriteString("</svg>")
+	return writeOutputFile(outputFilename, finishSVG(svg.Bytes(), opts.Minify, false))
+}
+
+// writeSparkline draws the monthly totals as a polyline scaled to the busiest
+// month, with its top edge at offsetY. With dots the lowest and highest months
+// are marked, the highest in the brightest bucket color.
+func writeSparkline(svg *bytes.Buffer, months []PeriodTotal, width, offsetY int, dots bool, theme Theme) {
+	if len(months) == 0 {
+		return
+	}
+	maxCount, minIndex, maxIndex := 0, 0, 0
+	for i, m := range months {
+		if m.Count > months[maxIndex].Count {
+			maxIndex = i
+		}
+		if m.Count < months[minIndex].Count {
+			minIndex = i
+		}
+		maxCount = max(maxCount, m.Count)
+	}
+
+	plotWidth := float64(width - 2*sparklinePadding)
+	plotHeight := float64(sparklineHeight - 2*sparklinePadding)
+	point := func(i int) (float64, float64) {
+		x := float64(sparklinePadding)
+		if len(months) > 1 {
+			x += plotWidth * float64(i) / float64(len(months)-1)
+		}
+		y := float64(offsetY+sparklinePadding) + plotHeight
+		if maxCount > 0 {
+			y -= plotHeight * float64(months[i].Count) / float64(maxCount)
+		}
+		return x, y
+	}
+
+	points := make([]string, len(months))
+	for i := range months {
+		x, y := point(i)
+		points[i] = fmt.Sprintf("%0.1f,%0.1f", x, y)
+	}
+	line := readableOn(theme.midBucket(), theme.Background)
+	svg.WriteString(fmt.Sprintf(`<polyline points="%s" fill="none" stroke="%s" stroke-width="1.5" stroke-linejoin="round"/>`, strings.Join(points, " "), line))
+	svg.WriteString("\n")
+
+	if dots {
+		for _, mark := range []struct {
+			index int
+			color string
+		}{{minIndex, line}, {maxIndex, theme.brightestBucket()}} {
+			x, y := point(mark.index)
+			svg.WriteString(fmt.Sprintf(`<circle cx="%0.1f" cy="%0.1f" r="2" fill="%s"><title>%s</title></circle>`, x, y, mark.color, escapeXML(months[mark.index].Title)))
+			svg.WriteString("\n")
+		}
+	}
+}
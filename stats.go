@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// =============================================================================
+// Contribution Statistics
+// =============================================================================
+
+// Stats summarizes a contribution grid. Padding days (empty Date) are ignored.
+type Stats struct {
+	TotalContributions int
+	Days               int // number of real (non-padding) days
+	ActiveDays         int // days with at least one contribution
+	LongestStreak      int
+	LongestStreakStart string // first date of the longest streak
+	LongestStreakEnd   string // last date of the longest streak
+	CurrentStreak      int
+	MostActiveDate     string
+	MostActiveCount    int
+	AveragePerDay      float64
+	Goal               int // the --goal, or 0 when none was set
+	GoalDays           int // days with at least Goal contributions
+}
+
+// computeStats walks weeks chronologically and computes the summary. A streak
+// is a run of consecutive days with Count > 0. The current streak is the run
+// ending on the last day, or on the day before it when the last day (usually
+// today) has no contributions yet.
+func computeStats(weeks Weeks) Stats {
+	var stats Stats
+	run, runStart := 0, ""
+	lastCount, prevRun := 0, 0
+	for _, week := range weeks {
+		for _, day := range week {
+			if day.Date == "" {
+				continue
+			}
+			stats.Days++
+			stats.TotalContributions += day.Count
+			if day.Count > stats.MostActiveCount {
+				stats.MostActiveCount = day.Count
+				stats.MostActiveDate = day.Date
+			}
+			prevRun = run
+			if day.Count > 0 {
+				stats.ActiveDays++
+				if run == 0 {
+					runStart = day.Date
+				}
+				run++
+				if run > stats.LongestStreak {
+					stats.LongestStreak = run
+					stats.LongestStreakStart = runStart
+					stats.LongestStreakEnd = day.Date
+				}
+			} else {
+				run = 0
+			}
+			lastCount = day.Count
+		}
+	}
+	if lastCount > 0 {
+		stats.CurrentStreak = run
+	} else {
+		stats.CurrentStreak = prevRun
+	}
+	if stats.Days > 0 {
+		stats.AveragePerDay = float64(stats.TotalContributions) / float64(stats.Days)
+	}
+	return stats
+}
+
+// goalDays returns how many days in weeks have at least goal contributions;
+// padding days never count.
+func goalDays(weeks Weeks, goal int) int {
+	met := 0
+	for _, week := range weeks {
+		for _, day := range week {
+			if day.Date != "" && day.Count >= goal {
+				met++
+			}
+		}
+	}
+	return met
+}
+
+// printStats writes a human-readable summary of stats to w.
+func printStats(w io.Writer, label string, stats Stats) {
+	fmt.Fprintf(w, "Summary for %s:\n", label)
+	fmt.Fprintf(w, "  Total contributions: %d\n", stats.TotalContributions)
+	fmt.Fprintf(w, "  Longest streak:      %d days", stats.LongestStreak)
+	if stats.LongestStreak > 0 {
+		fmt.Fprintf(w, " (%s to %s)", stats.LongestStreakStart, stats.LongestStreakEnd)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "  Current streak:      %d days\n", stats.CurrentStreak)
+	if stats.MostActiveCount > 0 {
+		fmt.Fprintf(w, "  Most active day:     %s (%d contributions)\n", stats.MostActiveDate, stats.MostActiveCount)
+	} else {
+		fmt.Fprintln(w, "  Most active day:     none")
+	}
+	fmt.Fprintf(w, "  Average per day:     %.2f\n", stats.AveragePerDay)
+	if stats.Goal > 0 && stats.Days > 0 {
+		fmt.Fprintf(w, "  %-21s%d of %d days (%.1f%%)\n", fmt.Sprintf("Goal of %d met:", stats.Goal), stats.GoalDays, stats.Days, 100*float64(stats.GoalDays)/float64(stats.Days))
+	}
+}
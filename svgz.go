@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+)
+
+// =============================================================================
+// Gzipped SVG (--output svgz)
+// =============================================================================
+
+// writeSVGFile writes an SVG document to filename, gzip-compressed when
+// compress is set, as --output svgz asks.
+func writeSVGFile(filename string, data []byte, compress bool) error {
+	if !compress {
+		return writeOutputFile(filename, data)
+	}
+	compressed, err := gzipBytes(data)
+	if err != nil {
+		return err
+	}
+	if len(data) > 0 {
+		verboseLog.Printf("%s compressed from %d to %d bytes (%.1f%% smaller)", filename, len(data), len(compressed), 100*float64(len(data)-len(compressed))/float64(len(data)))
+	}
+	return writeOutputFile(filename, compressed)
+}
+
+// gzipBytes returns data gzip-compressed at the best compression level; the
+// maps are small, so the extra time does not matter.
+func gzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
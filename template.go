@@ -0,0 +1,326 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"text/template"
+	"time"
+)
+
+// =============================================================================
+// SVG Map Templates (--template)
+// =============================================================================
+
+// defaultMapTemplate is the markup of the contribution map. It is what every
+// map SVG is drawn with, what --template default names, and the place to
+// start when writing one's own.
+//
+//go:embed templates/map.svg.tmpl
+var defaultMapTemplate string
+
+// builtinMapTemplate is defaultMapTemplate parsed. Besides the whole map it
+// defines "grid", which stacked and combined SVGs draw each map with, and the
+// "zero-cell" and "auto-style" definitions they share.
+var builtinMapTemplate = template.Must(template.New("default").Funcs(mapTemplateFuncs).Parse(defaultMapTemplate))
+
+// mapTemplateFuncs are the functions map templates may call.
+var mapTemplateFuncs = template.FuncMap{
+	"xml":      escapeXML,
+	"contrast": contrastColor,
+}
+
+// mapTemplateData is what a map template is executed with. Coordinates are
+// already laid out, so a template only needs to decide the markup. Optional
+// parts are nil or empty when their option is off.
+type mapTemplateData struct {
+	Width, Height int // of the whole SVG
+	Label         string
+	Summary       string // the accessible description of the map
+	Total         int
+	CrossData     CrossData
+	Weeks         Weeks
+	Theme         Theme
+	AutoLight     *Theme // the light palette of --mode auto
+	Layout        MapLayout
+	TextColor     string        // readable on the theme background
+	TextClass     string        // "fg" with --mode auto, for its style to recolor text
+	FontSize      int           // of the month and weekday labels
+	Heading       *templateText // the --title header
+	Placeholder   *templateText // "No contributions", drawn instead of the grid when there are no weeks
+	MonthLabels   []MonthLabel
+	WeekdayLabels []MonthLabel
+	WeekendShade  string // the fill of WeekendBands
+	WeekendBands  []templateRect
+	ZeroCell      templateZeroCell
+	Cells         []templateCell // every day, week by week
+	Legend        *templateText  // the --highlight-streak note below the grid
+}
+
+// templateText is a line of text; X and Y are its anchor.
+type templateText struct {
+	X, Y, Size int
+	Color      string
+	Text       string
+}
+
+type templateRect struct {
+	X, Y, Width, Height int
+}
+
+// templateCellStyle is the corner radius and outline of a cell; zero values
+// leave them out.
+type templateCellStyle struct {
+	Radius      int
+	Stroke      string
+	StrokeWidth int
+}
+
+// templateZeroCell is the shared cell that days without contributions reuse.
+type templateZeroCell struct {
+	Size  int
+	Fill  string
+	Style templateCellStyle
+	Class string // --mode auto class
+}
+
+// templateCell is one day of the grid with its position.
+type templateCell struct {
+	X, Y, Size int
+	Week, Row  int // grid column and row
+	Date       string
+	Count      int
+	Color      string  // opaque, with any --continuous opacity blended in
+	Fill       string  // the cell color as drawn, under Opacity
+	Opacity    float64 // the --continuous fill-opacity; 0 for opaque
+	Tooltip    string  // as the built-in map shows it; empty on padding days
+	Shared     bool    // a day without contributions, drawn as the ZeroCell
+	Style      templateCellStyle
+	Class      string          // --mode auto classes
+	Link       string          // the --link target
+	Label      *templateText   // the --cell-labels count
+	GoalMarker *templateCircle // the --goal dot
+	Animation  string          // the --animate SMIL element, placed inside the cell and its decorations
+}
+
+type templateCircle struct {
+	CX, CY, R float64
+	Color     string
+}
+
+// loadMapTemplate parses the template named by --template: "default" for
+// the embedded one, or a file path. A file is parsed alongside the embedded
+// definitions, so it can use or redefine "grid", "cell" and the others.
+func loadMapTemplate(name string) (*template.Template, error) {
+	if name == "default" {
+		return builtinMapTemplate, nil
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := template.Must(builtinMapTemplate.Clone()).New(name).Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("parsing template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// renderTemplateSVG executes tmpl, the built-in map or a --template, for grid.
+func renderTemplateSVG(tmpl *template.Template, grid LabeledWeeks, opts MapOptions) ([]byte, error) {
+	data, err := newMapTemplateData(grid, opts)
+	if err != nil {
+		return nil, err
+	}
+	var svg bytes.Buffer
+	if err := tmpl.Execute(&svg, data); err != nil {
+		return nil, fmt.Errorf("executing template: %w", err)
+	}
+	return finishSVG(svg.Bytes(), opts.Minify, opts.StripTooltips), nil
+}
+
+// newMapTemplateData lays out the map of grid for a template. With a title,
+// the grid's total is the count written in the header; with opts.DayLink,
+// each nonzero cell is a link to the grid's user's contributions that day.
+func newMapTemplateData(grid LabeledWeeks, opts MapOptions) (mapTemplateData, error) {
+	width, height, err := mapGridSize(len(grid.Weeks), opts)
+	if err != nil {
+		return mapTemplateData{}, err
+	}
+	weeks := grid.Weeks
+	lightMode := opts.LightMode
+	layout := opts.Layout.forWeeks(len(weeks))
+	cellSize := layout.CellSize
+	cellMargin := layout.CellMargin
+	topMargin := layout.topMargin()
+	leftMargin := layout.leftMargin()
+	fontSize := layout.labelFontSize()
+
+	data := mapTemplateData{
+		Width:     width,
+		Height:    height,
+		Label:     grid.Label,
+		Summary:   mapSummary(weeks),
+		Total:     grid.Total,
+		CrossData: grid.CrossData,
+		Weeks:     weeks,
+		Theme:     opts.Theme,
+		AutoLight: opts.AutoLight,
+		Layout:    layout,
+		// Text sits directly on the theme background.
+		TextColor: contrastColor(opts.Theme.Background),
+		FontSize:  fontSize,
+		ZeroCell:  newTemplateZeroCell(opts),
+	}
+	if opts.AutoLight != nil {
+		data.TextClass = "fg"
+	}
+
+	if layout.Title {
+		data.Heading = &templateText{X: leftMargin + cellMargin, Y: layout.titleHeight() - fontSize/2, Size: layout.titleFontSize(), Color: data.TextColor, Text: totalHeading(grid.Total, opts.Window)}
+	}
+
+	if len(weeks) == 0 {
+		gridWidth := placeholderWeeks*(cellSize+cellMargin) + cellMargin
+		gridHeight := 7*(cellSize+cellMargin) + cellMargin
+		data.Placeholder = &templateText{X: leftMargin + gridWidth/2, Y: layout.titleHeight() + topMargin + gridHeight/2, Size: 2 * fontSize, Color: data.TextColor, Text: "No contributions"}
+		return data, nil
+	}
+
+	data.MonthLabels = monthLabels(weeks, layout)
+	bands := layout.gridBands(len(weeks))
+
+	// Weekday labels in the left gutter of each band; rows run Sunday through Saturday.
+	if layout.WeekdayLabels {
+		for band := 0; band < bands; band++ {
+			for dayIndex, label := range layout.weekdayLabels() {
+				if label == "" {
+					continue
+				}
+				_, y := layout.cellOrigin(band*layout.weeksPerRow, dayIndex)
+				data.WeekdayLabels = append(data.WeekdayLabels, MonthLabel{X: 0, Y: y + cellSize/2, Label: label})
+			}
+		}
+	}
+
+	// Weekend rows get a faint band beneath the cells, leaving cell colors as they are.
+	if opts.ShadeWeekends {
+		data.WeekendShade = "#1c1c1c"
+		if lightMode {
+			data.WeekendShade = "#eeeeee"
+		}
+		pitch := cellSize + cellMargin
+		for band := 0; band < bands; band++ {
+			for _, dayIndex := range []int{layout.weekdayRow(time.Sunday), layout.weekdayRow(time.Saturday)} {
+				_, y := layout.cellOrigin(band*layout.weeksPerRow, dayIndex)
+				data.WeekendBands = append(data.WeekendBands, templateRect{X: leftMargin, Y: y - cellMargin/2, Width: layout.gridColumns(len(weeks))*pitch + cellMargin, Height: pitch})
+			}
+		}
+	}
+
+	// The longest streak is outlined in a color that stands out from the palette.
+	var streak Stats
+	streakStroke := "#ffd33d"
+	if lightMode {
+		streakStroke = "#d73a49"
+	}
+	if opts.HighlightStreak {
+		streak = computeStats(weeks)
+	}
+
+	for weekIndex, week := range weeks {
+		for dayIndex, day := range week {
+			x, y := layout.cellOrigin(weekIndex, dayIndex)
+			inStreak := streak.LongestStreak > 0 && day.Date != "" && day.Date >= streak.LongestStreakStart && day.Date <= streak.LongestStreakEnd
+			cell := templateCell{
+				X: x, Y: y, Size: cellSize,
+				Week: weekIndex, Row: dayIndex,
+				Date: day.Date, Count: day.Count,
+				Color: day.solidColor(opts.Theme.Background),
+				Fill:  day.Color,
+				// Days without contributions, usually most of them, reuse
+				// one shared definition and only carry their position and
+				// tooltip.
+				Shared:    day.Count == 0 && !inStreak,
+				Style:     newTemplateCellStyle(layout, lightMode),
+				Animation: cellAnimation(weekIndex, len(weeks), opts.Animate),
+			}
+			if day.Opacity > 0 && day.Opacity < 1 {
+				cell.Opacity = day.Opacity
+			}
+			if day.Date != "" {
+				cell.Tooltip = opts.dayTooltip(day.Date, day.Count)
+			}
+			if inStreak {
+				cell.Style = templateCellStyle{Radius: layout.cornerRadius(), Stroke: streakStroke, StrokeWidth: 2}
+			}
+			if opts.AutoLight != nil {
+				if bucket := slices.Index(opts.Theme.Buckets, day.Color); bucket >= 0 {
+					cell.Class = fmt.Sprintf("b%d", bucket)
+					if !inStreak {
+						cell.Class += " c"
+					}
+				} else if day.Color == opts.Theme.Zero && !inStreak {
+					// A count under --count-threshold, drawn as its own rect.
+					cell.Class = "z"
+				}
+			}
+			if opts.DayLink != nil && grid.Label != "" && day.Date != "" && day.Count > 0 {
+				cell.Link = opts.DayLink(grid.Label, day.Date)
+			}
+			if opts.CellLabels && day.Count > 0 {
+				cell.Label = newCellLabel(x, y, cellSize, cell.Color, day.Count)
+			}
+			if opts.Goal > 0 && day.Date != "" && day.Count >= opts.Goal {
+				radius := max(float64(cellSize)/8, 1)
+				cell.GoalMarker = &templateCircle{CX: float64(x+cellSize) - radius - 1, CY: float64(y) + radius + 1, R: radius, Color: contrastColor(cell.Color)}
+			}
+			data.Cells = append(data.Cells, cell)
+		}
+	}
+
+	if opts.HighlightStreak {
+		gridHeight := bands*layout.bandHeight() - topMargin
+		legend := "No contribution streak"
+		if streak.LongestStreak > 0 {
+			legend = fmt.Sprintf("Longest streak: %d days (%s to %s)", streak.LongestStreak, streak.LongestStreakStart, streak.LongestStreakEnd)
+		}
+		data.Legend = &templateText{X: leftMargin + cellMargin, Y: layout.titleHeight() + topMargin + gridHeight + opts.legendHeight() - 4, Size: fontSize, Color: streakStroke, Text: legend}
+	}
+	return data, nil
+}
+
+// newTemplateCellStyle returns the corner radius and outline shared by every
+// cell outside the highlighted streak.
+func newTemplateCellStyle(layout MapLayout, lightMode bool) templateCellStyle {
+	style := templateCellStyle{Radius: layout.cornerRadius()}
+	if !lightMode {
+		style.Stroke, style.StrokeWidth = "#333333", 1
+	}
+	return style
+}
+
+// newTemplateZeroCell returns the shared cell of the maps drawn with opts.
+func newTemplateZeroCell(opts MapOptions) templateZeroCell {
+	zero := templateZeroCell{Size: opts.Layout.CellSize, Fill: opts.Theme.Zero, Style: newTemplateCellStyle(opts.Layout, opts.LightMode)}
+	if opts.AutoLight != nil {
+		zero.Class = "z"
+	}
+	return zero
+}
+
+// newCellLabel centers count inside its cell in a color that stands out
+// against the fill, or returns nil for counts too wide for the cell at a
+// legible size.
+func newCellLabel(x, y, cellSize int, fill string, count int) *templateText {
+	label := strconv.Itoa(count)
+	fontSize := cellSize / 2
+	// Sans-serif digits are roughly 0.6em wide.
+	if fontSize < minCellLabelFontSize || float64(len(label))*0.6*float64(fontSize) > float64(cellSize-2) {
+		return nil
+	}
+	return &templateText{X: x + cellSize/2, Y: y + cellSize/2, Size: fontSize, Color: contrastColor(fill), Text: label}
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"math"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// =============================================================================
+// Color Themes
+// =============================================================================
+
+// Theme holds the palette used by both the contribution map and the cross
+// diagram. Buckets run from the lowest nonzero bucket to the brightest.
+// A transparent theme draws no background; Background is then the page color
+// the text colors are chosen to stand out against.
+type Theme struct {
+	Background  string   `json:"background"`
+	Zero        string   `json:"zero"`
+	Buckets     []string `json:"buckets"`
+	Transparent bool     `json:"transparent,omitempty"`
+}
+
+// builtinThemes are the named themes selectable with --theme <name>.
+var builtinThemes = map[string]Theme{
+	"dark": {
+		Background: bgDark,
+		Zero:       zeroColorDark,
+		Buckets:    darkBucketColors,
+	},
+	"light": {
+		Background: bgLight,
+		Zero:       zeroColorLight,
+		Buckets:    lightBucketColors,
+	},
+	"github": {
+		Background: "#ffffff",
+		Zero:       "#ebedf0",
+		Buckets:    []string{"#9be9a8", "#40c463", "#30a14e", "#216e39", "#0e4429"},
+	},
+	"dracula": {
+		Background: "#282a36",
+		Zero:       "#44475a",
+		Buckets:    []string{"#4b3b6b", "#6e4f9e", "#9166cc", "#bd93f9", "#ff79c6"},
+	},
+	"solarized": {
+		Background: "#002b36",
+		Zero:       "#073642",
+		Buckets:    []string{"#586e75", "#268bd2", "#2aa198", "#859900", "#b58900"},
+	},
+}
+
+// parseHexColor parses a #RGB or #RRGGBB color string.
+func parseHexColor(s string) (color.RGBA, error) {
+	hex, ok := strings.CutPrefix(s, "#")
+	if !ok || (len(hex) != 3 && len(hex) != 6) {
+		return color.RGBA{}, fmt.Errorf("%q is not a hex color: use #RGB or #RRGGBB", s)
+	}
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.RGBA{}, fmt.Errorf("%q is not a hex color: use #RGB or #RRGGBB", s)
+	}
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xff}, nil
+}
+
+// defaultTheme returns the built-in palette matching the light/dark mode.
+func defaultTheme(lightMode bool) Theme {
+	if lightMode {
+		return builtinThemes["light"]
+	}
+	return builtinThemes["dark"]
+}
+
+// resolveTheme builds the theme to render with. The name selects a built-in
+// theme or, if it is not one, a JSON theme file; an empty name falls back to the
+// default palette for the mode. A gradient, when both ends are set, replaces
+// its bucket colors with the two endpoints. The buckets are then resampled to
+// the given count. Each override has the form key=#hex, where key is
+// background, zero or bucket1..bucketN, and is applied on top.
+func resolveTheme(name string, lightMode bool, gradient [2]string, buckets int, overrides []string) (Theme, error) {
+	theme := defaultTheme(lightMode)
+	if name != "" {
+		if builtin, ok := builtinThemes[name]; ok {
+			theme = builtin
+		} else {
+			loaded, err := loadThemeFile(name)
+			if err != nil {
+				return Theme{}, err
+			}
+			theme = loaded
+		}
+	}
+	if gradient != [2]string{} {
+		for _, end := range gradient {
+			if _, err := parseHexColor(end); err != nil {
+				return Theme{}, fmt.Errorf("invalid gradient color: %w", err)
+			}
+		}
+		theme.Buckets = gradient[:]
+	}
+	theme = theme.withBuckets(buckets)
+
+	for _, override := range overrides {
+		key, value, ok := strings.Cut(override, "=")
+		if !ok {
+			return Theme{}, fmt.Errorf("invalid color %q: expected key=#hex", override)
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		switch {
+		case key == "background":
+			theme.Background = value
+		case key == "zero":
+			theme.Zero = value
+		case strings.HasPrefix(key, "bucket"):
+			n, err := strconv.Atoi(strings.TrimPrefix(key, "bucket"))
+			if err != nil || n < 1 || n > len(theme.Buckets) {
+				return Theme{}, fmt.Errorf("invalid color key %q: use bucket1..bucket%d", key, len(theme.Buckets))
+			}
+			theme.Buckets[n-1] = value
+		default:
+			return Theme{}, fmt.Errorf("invalid color key %q: use background, zero or bucket1..bucket%d", key, len(theme.Buckets))
+		}
+	}
+
+	if err := theme.validate(); err != nil {
+		return Theme{}, err
+	}
+	return theme, nil
+}
+
+// loadThemeFile reads a theme from a JSON file of the form
+// {"background": "#...", "zero": "#...", "buckets": ["#...", ...]}.
+func loadThemeFile(path string) (Theme, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return Theme{}, fmt.Errorf("unknown theme %q: not a built-in theme (%s) or a readable file", path, strings.Join(builtinThemeNames(), ", "))
+		}
+		return Theme{}, fmt.Errorf("reading theme file %s: %w", path, err)
+	}
+	var theme Theme
+	if err := json.Unmarshal(data, &theme); err != nil {
+		return Theme{}, fmt.Errorf("parsing theme file %s: %w", path, err)
+	}
+	if len(theme.Buckets) < minBucketCount || len(theme.Buckets) > maxBucketCount {
+		return Theme{}, fmt.Errorf("theme file %s has %d bucket colors, want %d to %d", path, len(theme.Buckets), minBucketCount, maxBucketCount)
+	}
+	return theme, nil
+}
+
+// linearChannel converts an sRGB channel to linear light, from 0 to 1.
+func linearChannel(v uint8) float64 {
+	s := float64(v) / 255
+	if s <= 0.03928 {
+		return s / 12.92
+	}
+	return math.Pow((s+0.055)/1.055, 2.4)
+}
+
+// srgbChannel converts a linear light value back to an sRGB channel.
+func srgbChannel(l float64) uint8 {
+	s := 12.92 * l
+	if l > 0.0031308 {
+		s = 1.055*math.Pow(l, 1/2.4) - 0.055
+	}
+	return uint8(math.Round(math.Max(0, math.Min(1, s)) * 255))
+}
+
+// relativeLuminance returns the WCAG 2 relative luminance of c, from 0 for
+// black to 1 for white.
+func relativeLuminance(c color.RGBA) float64 {
+	return 0.2126*linearChannel(c.R) + 0.7152*linearChannel(c.G) + 0.0722*linearChannel(c.B)
+}
+
+// interpolateColor returns the color a fraction t of the way from a to b,
+// mixed in linear RGB so midpoints do not come out muddy. Invalid colors are
+// treated as black.
+func interpolateColor(a, b string, t float64) string {
+	ca, _ := parseHexColor(a)
+	cb, _ := parseHexColor(b)
+	mix := func(x, y uint8) uint8 {
+		lx, ly := linearChannel(x), linearChannel(y)
+		return srgbChannel(lx + (ly-lx)*t)
+	}
+	return fmt.Sprintf("#%02x%02x%02x", mix(ca.R, cb.R), mix(ca.G, cb.G), mix(ca.B, cb.B))
+}
+
+// contrastRatio returns the WCAG contrast ratio between two hex colors, from
+// 1 to 21. Invalid colors are treated as black.
+func contrastRatio(a, b string) float64 {
+	ca, _ := parseHexColor(a)
+	cb, _ := parseHexColor(b)
+	la, lb := relativeLuminance(ca), relativeLuminance(cb)
+	if la < lb {
+		la, lb = lb, la
+	}
+	return (la + 0.05) / (lb + 0.05)
+}
+
+// contrastColor returns black or white, whichever has the higher contrast
+// ratio against bg.
+func contrastColor(bg string) string {
+	if contrastRatio(bg, "#000000") >= contrastRatio(bg, "#ffffff") {
+		return "#000000"
+	}
+	return "#ffffff"
+}
+
+// readableOn returns fg when it has at least the WCAG large-text contrast
+// ratio of 3:1 against bg, and contrastColor(bg) otherwise.
+func readableOn(fg, bg string) string {
+	if contrastRatio(fg, bg) >= 3 {
+		return fg
+	}
+	return contrastColor(bg)
+}
+
+// blendColors returns fg drawn with the given opacity over bg, the color a
+// viewer shows for fill-opacity. Invalid colors are treated as black.
+func blendColors(bg, fg string, opacity float64) string {
+	b, _ := parseHexColor(bg)
+	f, _ := parseHexColor(fg)
+	mix := func(x, y uint8) uint8 {
+		return uint8(math.Round(float64(x) + (float64(y)-float64(x))*opacity))
+	}
+	return fmt.Sprintf("#%02x%02x%02x", mix(b.R, f.R), mix(b.G, f.G), mix(b.B, f.B))
+}
+
+// writeBackground fills a width by height SVG with the theme background,
+// unless the theme is transparent.
+func (t Theme) writeBackground(svg *bytes.Buffer, width, height int) {
+	t.writeBackgroundAttrs(svg, width, height, "")
+}
+
+// writeBackgroundAttrs is writeBackground with extra attributes, such as a
+// class, on the background rect.
+func (t Theme) writeBackgroundAttrs(svg *bytes.Buffer, width, height int, attrs string) {
+	if t.Transparent {
+		return
+	}
+	svg.WriteString(fmt.Sprintf(`<rect width="%d" height="%d" fill="%s"%s/>`, width, height, t.Background, attrs))
+	svg.WriteString("\n")
+}
+
+// withBuckets returns the theme with n bucket colors, spread evenly along its
+// palette from the darkest to the brightest and interpolated in between. The
+// bucket slice is always a copy, so it can be changed without touching t.
+func (t Theme) withBuckets(n int) Theme {
+	if n == len(t.Buckets) {
+		t.Buckets = slices.Clone(t.Buckets)
+		return t
+	}
+	buckets := make([]string, n)
+	last := len(t.Buckets) - 1
+	for i := range buckets {
+		pos := float64(i) * float64(last) / float64(n-1)
+		lo := min(int(pos), last-1)
+		buckets[i] = interpolateColor(t.Buckets[lo], t.Buckets[lo+1], pos-float64(lo))
+	}
+	t.Buckets = buckets
+	return t
+}
+
+// brightestBucket returns the color of the busiest days.
+func (t Theme) brightestBucket() string {
+	return t.Buckets[len(t.Buckets)-1]
+}
+
+// midBucket returns the middle bucket color, used for text and lines drawn in
+// the theme's own colors.
+func (t Theme) midBucket() string {
+	return t.Buckets[len(t.Buckets)/2]
+}
+
+// validate checks that every color in the theme is a valid hex color.
+func (t Theme) validate() error {
+	if _, err := parseHexColor(t.Background); err != nil {
+		return fmt.Errorf("invalid background color: %w", err)
+	}
+	if _, err := parseHexColor(t.Zero); err != nil {
+		return fmt.Errorf("invalid zero color: %w", err)
+	}
+	for i, c := range t.Buckets {
+		if _, err := parseHexColor(c); err != nil {
+			return fmt.Errorf("invalid bucket%d color: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// builtinThemeNames returns the names of the built-in themes in sorted order.
+func builtinThemeNames() []string {
+	names := make([]string, 0, len(builtinThemes))
+	for name := range builtinThemes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestInterpolateColor(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		t    float64
+		want string
+	}{
+		{"#000000", "#ffffff", 0, "#000000"},
+		{"#000000", "#ffffff", 1, "#ffffff"},
+		{"#000000", "#ffffff", 0.5, "#bcbcbc"}, // half the light, not half the value
+		{"#0e4429", "#39d353", 0, "#0e4429"},
+		{"#0e4429", "#39d353", 1, "#39d353"},
+	} {
+		if got := interpolateColor(tc.a, tc.b, tc.t); got != tc.want {
+			t.Errorf("interpolateColor(%s, %s, %g) = %s, want %s", tc.a, tc.b, tc.t, got, tc.want)
+		}
+	}
+}
+
+func TestWithBuckets(t *testing.T) {
+	theme := defaultTheme(false)
+	original := slices.Clone(theme.Buckets)
+	for n := minBucketCount; n <= maxBucketCount; n++ {
+		resampled := theme.withBuckets(n)
+		if len(resampled.Buckets) != n {
+			t.Errorf("withBuckets(%d) has %d buckets", n, len(resampled.Buckets))
+			continue
+		}
+		if !strings.EqualFold(resampled.Buckets[0], original[0]) || !strings.EqualFold(resampled.brightestBucket(), original[len(original)-1]) {
+			t.Errorf("withBuckets(%d) = %v, want it to run from %s to %s", n, resampled.Buckets, original[0], original[len(original)-1])
+		}
+		for _, c := range resampled.Buckets {
+			if _, err := parseHexColor(c); err != nil {
+				t.Errorf("withBuckets(%d): %v", n, err)
+			}
+		}
+		// The busiest day still gets the brightest of the resampled colors.
+		if got := getColor(40, 40, resampled); got != resampled.brightestBucket() {
+			t.Errorf("withBuckets(%d): the busiest day is %s", n, got)
+		}
+		resampled.Buckets[0] = "#123456"
+	}
+	if !slices.Equal(theme.Buckets, original) {
+		t.Errorf("withBuckets changed the original palette to %v", theme.Buckets)
+	}
+	if got := theme.withBuckets(len(original)).Buckets; !slices.Equal(got, original) {
+		t.Errorf("withBuckets(%d) = %v, want the palette unchanged", len(original), got)
+	}
+	if got := theme.withBuckets(9).Buckets[2]; !strings.EqualFold(got, original[1]) {
+		t.Errorf("bucket 3 of 9 = %s, want bucket 2 of 5 (%s), which it falls on", got, original[1])
+	}
+}
+
+func TestThemeFileBucketCount(t *testing.T) {
+	for n := minBucketCount - 1; n <= maxBucketCount+1; n++ {
+		buckets := make([]string, n)
+		for i := range buckets {
+			buckets[i] = fmt.Sprintf(`"#00%02x00"`, 20*i)
+		}
+		path := filepath.Join(t.TempDir(), "theme.json")
+		data := fmt.Sprintf(`{"background": "#000000", "zero": "#111111", "buckets": [%s]}`, strings.Join(buckets, ", "))
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := resolveTheme(path, false, [2]string{}, 7, nil)
+		if valid := n >= minBucketCount && n <= maxBucketCount; valid != (err == nil) {
+			t.Errorf("a theme file of %d buckets: err = %v", n, err)
+		}
+	}
+}
+
+func TestGradientLuminance(t *testing.T) {
+	for _, gradient := range [][2]string{
+		{"#001100", "#00ff00"},
+		{"#1e3a8a", "#fde68a"},
+		{"#ffffff", "#000000"}, // bright to dark, for light backgrounds
+	} {
+		from, _ := parseHexColor(gradient[0])
+		to, _ := parseHexColor(gradient[1])
+		rising := relativeLuminance(to) > relativeLuminance(from)
+		for n := minBucketCount; n <= maxBucketCount; n++ {
+			theme, err := resolveTheme("", false, gradient, n, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(theme.Buckets) != n || theme.Buckets[0] != gradient[0] || theme.brightestBucket() != gradient[1] {
+				t.Errorf("%v in %d buckets = %v, want it to run from end to end", gradient, n, theme.Buckets)
+				continue
+			}
+			for i := 1; i < n; i++ {
+				prev, _ := parseHexColor(theme.Buckets[i-1])
+				cur, _ := parseHexColor(theme.Buckets[i])
+				if (relativeLuminance(cur) > relativeLuminance(prev)) != rising {
+					t.Errorf("%v in %d buckets: luminance of %s does not follow %s", gradient, n, theme.Buckets[i], theme.Buckets[i-1])
+				}
+			}
+		}
+	}
+}
+
+func TestGradientFeedsCrossDiagram(t *testing.T) {
+	theme, err := resolveTheme("", false, [2]string{"#001100", "#00ff00"}, 5, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := testCrossOptions()
+	opts.Theme = theme
+	if svg := string(renderCrossSVG(CrossData{Commits: 3, Issues: 1}, opts)); !strings.Contains(svg, `fill="#00ff00"`) {
+		t.Errorf("the cross diagram dot is not drawn in the --gradient-to color:\n%s", svg)
+	}
+}